@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"geode/internal/cache"
+	"geode/internal/check"
 	"geode/internal/config"
+	"geode/internal/output"
 	"geode/internal/server"
+	"io"
 	"log"
 	"os"
 )
@@ -23,6 +28,12 @@ func main() {
 	case "build":
 		runBuild(os.Args[2:])
 
+	case "clean":
+		runClean(os.Args[2:])
+
+	case "check":
+		os.Exit(runCheck(os.Args[2:], os.Stdout))
+
 	default:
 		fmt.Println("Unknown command:", os.Args[1])
 		printUsage()
@@ -34,6 +45,7 @@ func runServe(args []string) {
 	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
 	port := serveCmd.Int("port", 3001, "application port")
 	contentDir := serveCmd.String("dir", "content", "content directory")
+	inMemory := serveCmd.Bool("memory", false, "keep the built site in memory instead of writing it to build.output")
 
 	serveCmd.Parse(args)
 
@@ -44,19 +56,26 @@ func runServe(args []string) {
 
 	fmt.Printf("Serving %s at http://localhost:%d\n", *contentDir, *port)
 
+	out := output.New(cfg)
+	if *inMemory {
+		out = output.NewMemory()
+	}
+
 	// build once
-	err = server.Rebuild(*contentDir, cfg, true)
-	if err != nil {
+	site := server.NewSite(*contentDir, cfg, out, true)
+	if err := site.Build(); err != nil {
 		log.Fatal(err)
 	}
 
-	go server.WatchAndRebuild(*contentDir, cfg)
-	server.ServePublic(*port)
+	hub := server.NewReloadHub()
+	go server.WatchAndRebuild(site, hub)
+	server.ServePublic(out, hub, *port)
 }
 
 func runBuild(args []string) {
 	buildCmd := flag.NewFlagSet("build", flag.ExitOnError)
 	contentDir := buildCmd.String("dir", "content", "content directory")
+	jobs := buildCmd.Int("jobs", 0, "number of parallel workers (default: number of CPUs)")
 
 	buildCmd.Parse(args)
 
@@ -65,6 +84,10 @@ func runBuild(args []string) {
 		log.Fatal(err)
 	}
 
+	if *jobs > 0 {
+		cfg.Build.Jobs = *jobs
+	}
+
 	fmt.Println("Building from:", *contentDir)
 	err = server.Rebuild(*contentDir, cfg, false)
 	if err != nil {
@@ -72,8 +95,73 @@ func runBuild(args []string) {
 	}
 }
 
+// runCheck prints the problems check finds to stdout and returns the exit
+// status: 1 when there are any.
+func runCheck(args []string, stdout io.Writer) int {
+	checkCmd := flag.NewFlagSet("check", flag.ExitOnError)
+	contentDir := checkCmd.String("dir", "content", "content directory")
+	format := checkCmd.String("format", check.FormatText, "output format: text or json")
+	external := checkCmd.Bool("external", false, "also check links to other sites")
+
+	checkCmd.Parse(args)
+
+	if *format != check.FormatText && *format != check.FormatJSON {
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	problems, err := check.Run(context.Background(), *contentDir, cfg, check.Options{External: *external})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := check.Write(stdout, problems, *format); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runClean(args []string) {
+	cleanCmd := flag.NewFlagSet("clean", flag.ExitOnError)
+	clearCache := cleanCmd.Bool("cache", false, "also remove the build cache")
+
+	cleanCmd.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Removing:", cfg.Build.Output)
+	if err := os.RemoveAll(cfg.Build.Output); err != nil {
+		log.Fatal(err)
+	}
+
+	if *clearCache {
+		fmt.Println("Removing:", cache.Dir(cfg))
+		if err := cache.Clear(cfg); err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println("Removing:", cfg.Check.External.Cache)
+		if err := os.RemoveAll(cfg.Check.External.Cache); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
 func printUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("  geode build [flags]")
 	fmt.Println("  geode serve [flags]")
+	fmt.Println("  geode clean [--cache]")
+	fmt.Println("  geode check [--format json] [--external]")
 }
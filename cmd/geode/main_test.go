@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCheckExitStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		note       string
+		args       []string
+		wantStatus int
+		wantOutput string
+	}{
+		{"clean", "# Note\n", nil, 0, "No problems found.\n"},
+		{"broken link", "[[Missing]]\n", nil, 1, "vault/note.md:1: unresolved link [[Missing]]\n1 problems found.\n"},
+		{"clean json", "# Note\n", []string{"-format", "json"}, 0, "[]\n"},
+		{"broken link json", "[[Missing]]\n", []string{"-format", "json"}, 1, `"message": "unresolved link [[Missing]]"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			files := map[string]string{
+				"geode.config.yaml":               "site:\n  name: Test\n  base_url: https://example.com\nbuild:\n  output: public\n  mode: draft\ntheme: test\n",
+				"themes/test/templates/base.html": "{{.Content}}\n",
+				"themes/test/templates/tag.html":  "{{.Tag}}\n",
+				"themes/test/templates/tags.html": "tags\n",
+				"themes/test/assets/style.css":    "body {}\n",
+				"vault/note.md":                   tt.note,
+			}
+			for rel, data := range files {
+				if err := os.MkdirAll(filepath.Dir(rel), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(rel, []byte(data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var stdout bytes.Buffer
+			status := runCheck(append([]string{"-dir", "vault"}, tt.args...), &stdout)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if !strings.Contains(stdout.String(), tt.wantOutput) {
+				t.Errorf("output =\n%s\nwant it to contain\n%s", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
@@ -6,10 +6,8 @@ require (
 	github.com/alecthomas/chroma/v2 v2.21.1
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/yuin/goldmark v1.7.13
+	golang.org/x/sys v0.13.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/dlclark/regexp2 v1.11.5 // indirect
-	golang.org/x/sys v0.13.0 // indirect
-)
+require github.com/dlclark/regexp2 v1.11.5 // indirect
@@ -6,6 +6,7 @@ import (
 	"geode/internal/types"
 	"geode/internal/utils"
 	"html"
+	"strings"
 )
 
 func BuildGraph(page types.MetaMarkdown) *types.GraphData {
@@ -32,10 +33,12 @@ func BuildGraph(page types.MetaMarkdown) *types.GraphData {
 	ensureNode(currentPageURL, page.Title)
 
 	for _, out := range page.OutgoingLinks {
-		ensureNode(out.URL, out.Title)
+		// Links to a heading point at the same page node.
+		target, _, _ := strings.Cut(out.URL, "#")
+		ensureNode(target, out.Title)
 		links = append(links, types.GraphLink{
 			Source: currentPageURL,
-			Target: out.URL,
+			Target: target,
 		})
 	}
 
@@ -0,0 +1,78 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/types"
+)
+
+// ReportLinks prints the wikilinks that point at no published note, grouped
+// by the note they appear in: first the links to notes that do not exist,
+// then the links to unpublished notes. Under the "error" policy, links to
+// unpublished notes fail the build.
+func ReportLinks(cfg *config.Config, pages []types.MetaMarkdown) error {
+	unresolved := reportLinks(pages, false)
+	unpublished := reportLinks(pages, true)
+
+	printLinks("Unresolved links", unresolved)
+	printLinks("Links to unpublished notes", unpublished)
+
+	if cfg.Build.UnpublishedLinks == config.UnpublishedError && len(unpublished) > 0 {
+		paths := make([]string, len(unpublished))
+		for i, note := range unpublished {
+			paths[i] = note.path
+		}
+		return fmt.Errorf("links to unpublished notes in %s", strings.Join(paths, ", "))
+	}
+
+	return nil
+}
+
+type noteLinks struct {
+	path    string
+	targets []string
+}
+
+// reportLinks returns the distinct targets of the unresolved links of every
+// page that has some.
+func reportLinks(pages []types.MetaMarkdown, unpublished bool) []noteLinks {
+	var out []noteLinks
+
+	for _, page := range pages {
+		var targets []string
+		seen := make(map[string]struct{})
+		for _, link := range page.UnresolvedLinks {
+			if link.Unpublished != unpublished {
+				continue
+			}
+			target := "[[" + link.Target + "]]"
+			if link.Embedded != "" {
+				target += " in " + link.Embedded
+			}
+			if _, ok := seen[target]; ok {
+				continue
+			}
+			seen[target] = struct{}{}
+			targets = append(targets, target)
+		}
+
+		if len(targets) > 0 {
+			out = append(out, noteLinks{path: page.RelativePath, targets: targets})
+		}
+	}
+
+	return out
+}
+
+func printLinks(title string, notes []noteLinks) {
+	if len(notes) == 0 {
+		return
+	}
+
+	fmt.Printf("%s:\n", title)
+	for _, note := range notes {
+		fmt.Printf("  %s: %s\n", note.path, strings.Join(note.targets, ", "))
+	}
+}
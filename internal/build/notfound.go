@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"geode/internal/config"
+	"geode/internal/output"
 	"geode/internal/types"
 )
 
@@ -19,7 +20,7 @@ type NotFoundData struct {
 	HasMermaid bool
 }
 
-func Build404(cfg *config.Config, liveReload bool, fileTree *types.FileTree) error {
+func Build404(cfg *config.Config, out output.Output, liveReload bool, fileTree *types.FileTree) error {
 	templatePath := filepath.Join("themes", cfg.Theme, "templates", "404.html")
 	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
 		return nil
@@ -39,8 +40,11 @@ func Build404(cfg *config.Config, liveReload bool, fileTree *types.FileTree) err
 		HasMermaid: false,
 	}
 
-	outPath := filepath.Join("public", "404.html")
-	f, err := os.Create(outPath)
+	if err := out.Claim("404.html", templatePath); err != nil {
+		return err
+	}
+
+	f, err := out.Create("404.html")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,97 @@
+package build
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"path"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/output"
+	"geode/internal/types"
+	"geode/internal/utils"
+)
+
+var redirectTemplate = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8" />
+    <title>{{ .Title }}</title>
+    <link rel="canonical" href="{{ .Canonical }}" />
+    <meta name="robots" content="noindex" />
+    <meta http-equiv="refresh" content="0; url={{ .URL }}" />
+  </head>
+  <body>
+    <p>Redirecting to <a href="{{ .URL }}">{{ .Title }}</a>.</p>
+  </body>
+</html>
+`))
+
+type redirectData struct {
+	Title     string
+	URL       string
+	Canonical string
+}
+
+// BuildRedirects writes a redirect stub at the URL of every alias of a page,
+// next to the page itself, and at every URL listed in its redirect_from.
+// Stubs never replace a real page: a URL that is already taken is skipped
+// with a warning. A URL outside the output directory fails the build.
+func BuildRedirects(cfg *config.Config, out output.Output, pages []types.MetaMarkdown) error {
+	for _, page := range pages {
+		if page.Link == "" {
+			continue
+		}
+
+		var from []string
+		dir := path.Dir(strings.TrimPrefix(page.Link, "/"))
+		for _, alias := range page.Aliases {
+			from = append(from, "/"+path.Join(dir, utils.PathToSlug(alias)))
+		}
+		for _, url := range page.RedirectFrom {
+			from = append(from, "/"+strings.Trim(utils.PathToSlug(url), "/"))
+		}
+
+		data := redirectData{
+			Title:     page.Title,
+			URL:       page.Link,
+			Canonical: strings.TrimSuffix(cfg.Site.BaseURL, "/") + page.Link,
+		}
+
+		for _, url := range from {
+			if url == page.Link {
+				continue
+			}
+
+			rel := output.PagePath(url)
+			if err := output.CheckPath(rel); err != nil {
+				return fmt.Errorf("redirect from %s to %s: %w", url, page.RelativePath, err)
+			}
+			if err := out.Claim(rel, "redirect to "+page.RelativePath); err != nil {
+				log.Printf("skip redirect: %v", err)
+				continue
+			}
+
+			if err := writeRedirect(out, rel, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeRedirect(out output.Output, rel string, data redirectData) error {
+	f, err := out.Create(rel)
+	if err != nil {
+		return err
+	}
+
+	if err := redirectTemplate.Execute(f, data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,89 @@
+package build
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"geode/internal/config"
+	"geode/internal/output"
+	"geode/internal/types"
+)
+
+func TestBuildRedirects(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Site.BaseURL = "https://example.com/"
+
+	pages := []types.MetaMarkdown{
+		{
+			RelativePath: "guides/Setup.md",
+			Link:         "/guides/setup",
+			Title:        `Setup & "Install"`,
+			Aliases:      []string{"Install Guide", "setup"},
+			RedirectFrom: []string{"/old/setup/", "Start Here"},
+		},
+		{
+			RelativePath: "Home.md",
+			Link:         "/",
+			Aliases:      []string{"Start"},
+		},
+		{RelativePath: "Taken.md", Link: "/taken"},
+		{RelativePath: "Other.md", Link: "/other", RedirectFrom: []string{"/taken"}},
+		{RelativePath: "NoLink.md", Aliases: []string{"Ghost"}},
+	}
+
+	out := output.NewMemory()
+	if err := out.Claim("taken.html", "Taken.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := BuildRedirects(cfg, out, pages); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"guides/Install-Guide.html", []string{
+			`<title>Setup &amp; &#34;Install&#34;</title>`,
+			`<link rel="canonical" href="https://example.com/guides/setup" />`,
+			`<meta http-equiv="refresh" content="0; url=/guides/setup" />`,
+			`<a href="/guides/setup">Setup &amp; &#34;Install&#34;</a>`,
+		}},
+		{"old/setup.html", []string{`href="https://example.com/guides/setup"`}},
+		{"Start-Here.html", []string{`url=/guides/setup"`}},
+		{"Start.html", []string{`<link rel="canonical" href="https://example.com/" />`, `url=/"`}},
+	}
+
+	for _, tt := range tests {
+		data, err := fs.ReadFile(out.FS(), tt.file)
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s lacks %s:\n%s", tt.file, want, data)
+			}
+		}
+	}
+
+	// An alias matching the page's own URL, a URL taken by another page and
+	// pages without a URL get no stub.
+	for _, file := range []string{"guides/setup.html", "taken.html", "Ghost.html"} {
+		if _, err := fs.Stat(out.FS(), file); err == nil {
+			t.Errorf("%s was written", file)
+		}
+	}
+}
+
+func TestBuildRedirectsOutsideOutput(t *testing.T) {
+	pages := []types.MetaMarkdown{
+		{RelativePath: "a.md", Link: "/a", RedirectFrom: []string{"../../etc/a"}},
+	}
+
+	err := BuildRedirects(&config.Config{}, output.NewMemory(), pages)
+	if err == nil || !strings.Contains(err.Error(), "outside the output directory") {
+		t.Errorf("BuildRedirects() = %v, want an error", err)
+	}
+}
@@ -4,9 +4,8 @@ import (
 	"encoding/xml"
 	"fmt"
 	"geode/internal/config"
+	"geode/internal/output"
 	"geode/internal/types"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 )
@@ -21,12 +20,7 @@ type UrlSet struct {
 	URLs    []SitemapURL `xml:"url"`
 }
 
-func BuildSitemap(cfg *config.Config, pages []types.MetaMarkdown) error {
-	outputDir := cfg.Build.Output
-	if outputDir == "" {
-		outputDir = "public"
-	}
-
+func BuildSitemap(cfg *config.Config, out output.Output, pages []types.MetaMarkdown) error {
 	var urls []SitemapURL
 	baseURL := cfg.Site.BaseURL
 
@@ -70,7 +64,7 @@ func BuildSitemap(cfg *config.Config, pages []types.MetaMarkdown) error {
 
 	urlSet := UrlSet{URLs: urls}
 
-	f, err := os.Create(filepath.Join(outputDir, "sitemap.xml"))
+	f, err := out.Create("sitemap.xml")
 	if err != nil {
 		return fmt.Errorf("create sitemap.xml: %w", err)
 	}
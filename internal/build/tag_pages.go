@@ -3,12 +3,12 @@ package build
 import (
 	"fmt"
 	"html/template"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"geode/internal/config"
+	"geode/internal/output"
 	"geode/internal/types"
 	"geode/internal/utils"
 )
@@ -26,7 +26,7 @@ type TagDetailData struct {
 	Pages      []TagIndexPage
 }
 
-func BuildTagPages(cfg *config.Config, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+func BuildTagPages(cfg *config.Config, out output.Output, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
 	templatePath := filepath.Join("themes", cfg.Theme, "templates", "tag.html")
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
@@ -95,11 +95,11 @@ func BuildTagPages(cfg *config.Config, pages []types.MetaMarkdown, liveReload bo
 			Pages:      items,
 		}
 
-		outPath := filepath.Join("public", "tags", escapeTagPath(tag)+".html")
-		if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		outPath := output.PagePath("/tags/" + escapeTagPath(tag))
+		if err := out.Claim(outPath, "tag #"+tag); err != nil {
 			return err
 		}
-		f, err := os.Create(outPath)
+		f, err := out.Create(outPath)
 		if err != nil {
 			return err
 		}
@@ -3,12 +3,12 @@ package build
 import (
 	"fmt"
 	"html/template"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"geode/internal/config"
+	"geode/internal/output"
 	"geode/internal/types"
 	"geode/internal/utils"
 )
@@ -41,7 +41,7 @@ type TagIndexData struct {
 	TagGroups []TagIndexGroup
 }
 
-func BuildTagsIndex(cfg *config.Config, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+func BuildTagsIndex(cfg *config.Config, out output.Output, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
 	templatePath := filepath.Join("themes", cfg.Theme, "templates", "tags.html")
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
@@ -119,12 +119,11 @@ func BuildTagsIndex(cfg *config.Config, pages []types.MetaMarkdown, liveReload b
 		TagGroups:  groups,
 	}
 
-	outPath := filepath.Join("public", "tags.html")
-	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+	if err := out.Claim("tags.html", "tags index"); err != nil {
 		return err
 	}
 
-	f, err := os.Create(outPath)
+	f, err := out.Create("tags.html")
 	if err != nil {
 		return err
 	}
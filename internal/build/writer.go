@@ -3,12 +3,12 @@ package build
 import (
 	"fmt"
 	"html/template"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"geode/internal/config"
+	"geode/internal/output"
 	"geode/internal/types"
 	"geode/internal/utils"
 )
@@ -30,6 +30,7 @@ type PageData struct {
 	HasKatex      bool
 	HasMermaid    bool
 	HasTwitter    bool
+	HasEmbeds     bool
 	LiveReload    bool
 	CSSClasses    []string
 	Description   string
@@ -39,9 +40,10 @@ type PageData struct {
 type HTMLWriter struct {
 	tmpl *template.Template
 	cfg  *config.Config
+	out  output.Output
 }
 
-func NewHTMLWriter(cfg *config.Config) (*HTMLWriter, error) {
+func NewHTMLWriter(cfg *config.Config, out output.Output) (*HTMLWriter, error) {
 	templatePath := filepath.Join("themes", cfg.Theme, "templates", "base.html")
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
@@ -51,22 +53,40 @@ func NewHTMLWriter(cfg *config.Config) (*HTMLWriter, error) {
 	return &HTMLWriter{
 		tmpl: tmpl,
 		cfg:  cfg,
+		out:  out,
 	}, nil
 }
 
-func (w *HTMLWriter) Write(page types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
-	var cleanPath string
-	cleanPath = strings.TrimSuffix(utils.PathToSlug(page.RelativePath), ".md")
-	outputPath := filepath.Join("public", cleanPath+".html")
+// WriteAll claims the output paths of all pages up front, in page order, so
+// collisions are reported deterministically, then renders them on up to jobs
+// goroutines.
+func (w *HTMLWriter) WriteAll(pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree, jobs int) error {
+	for _, page := range pages {
+		if err := w.out.Claim(output.PagePath(pageURL(page)), page.RelativePath); err != nil {
+			return fmt.Errorf("write html %s: %w", page.RelativePath, err)
+		}
+	}
 
-	err := os.MkdirAll(filepath.Dir(outputPath), 0o755)
-	if err != nil {
-		return err
+	errs := make([]error, len(pages))
+	utils.Parallel(len(pages), jobs, func(_, i int) {
+		errs[i] = w.Write(pages[i], liveReload, fileTree)
+	})
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("write html %s: %w", pages[i].RelativePath, err)
+		}
 	}
 
-	currentPageURL := page.Link
-	if currentPageURL == "" {
-		currentPageURL = "/" + utils.PathToSlug(page.RelativePath)
+	return nil
+}
+
+func (w *HTMLWriter) Write(page types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+	currentPageURL := pageURL(page)
+
+	outputPath := output.PagePath(currentPageURL)
+	if err := w.out.Claim(outputPath, page.RelativePath); err != nil {
+		return err
 	}
 
 	outgoingHTML := RenderLinkList(page.OutgoingLinks)
@@ -94,13 +114,14 @@ func (w *HTMLWriter) Write(page types.MetaMarkdown, liveReload bool, fileTree *t
 		HasKatex:      page.HasKatex,
 		HasMermaid:    page.HasMermaid,
 		HasTwitter:    strings.Contains(page.HTML, `blockquote class="twitter-tweet"`),
+		HasEmbeds:     strings.Contains(page.HTML, `class="embed-placeholder"`),
 		LiveReload:    liveReload,
 		CSSClasses:    parseCSSClasses(page.Frontmatter),
 		Description:   page.Description,
 		Keywords:      parseKeywords(page.Frontmatter),
 	}
 
-	file, err := os.Create(outputPath)
+	file, err := w.out.Create(outputPath)
 	if err != nil {
 		return err
 	}
@@ -109,6 +130,13 @@ func (w *HTMLWriter) Write(page types.MetaMarkdown, liveReload bool, fileTree *t
 	return w.tmpl.Execute(file, data)
 }
 
+func pageURL(page types.MetaMarkdown) string {
+	if page.Link != "" {
+		return page.Link
+	}
+	return "/" + strings.TrimSuffix(utils.PathToSlug(page.RelativePath), ".md")
+}
+
 func parseCSSClasses(front map[string]any) []string {
 	v, ok := front["cssclasses"]
 	if !ok || v == nil {
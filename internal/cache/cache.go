@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"geode/internal/config"
+	"geode/internal/types"
+	"geode/internal/version"
+)
+
+const (
+	DefaultDir   = ".geode-cache"
+	manifestFile = "manifest"
+)
+
+// Entry is everything goldmark produces for a single note.
+type Entry struct {
+	HTML          string          `json:"html"`
+	OutgoingLinks []types.Link    `json:"outgoing_links"`
+	Toc           []types.TocItem `json:"toc"`
+	Tags          []string        `json:"tags"`
+	HasKatex      bool            `json:"has_katex"`
+	HasMermaid    bool            `json:"has_mermaid"`
+	Warnings      []string        `json:"warnings"`
+
+	UnresolvedLinks []types.UnresolvedLink `json:"unresolved_links"`
+
+	// Dependencies are the link targets the note resolved. The entry is
+	// only valid while each of them still has the same resolution.
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+// Dependency is a link target resolved from the note at From, and a digest of
+// what it resolved to.
+type Dependency struct {
+	From       string `json:"from"`
+	Target     string `json:"target"`
+	Path       bool   `json:"path,omitempty"`
+	Resolution string `json:"resolution"`
+}
+
+// Cache stores rendered notes on disk, keyed by a hash of their content.
+// Everything else a note depends on is recorded in its entry.
+// The whole cache is dropped when the geode version, the config file or the
+// theme changes. A nil cache stores nothing.
+type Cache struct {
+	dir string
+
+	mu   sync.Mutex
+	used map[string]struct{}
+}
+
+func Dir(cfg *config.Config) string {
+	if cfg.Build.Cache != "" {
+		return cfg.Build.Cache
+	}
+	return DefaultDir
+}
+
+func Open(cfg *config.Config) (*Cache, error) {
+	dir := Dir(cfg)
+
+	fingerprint, err := Fingerprint(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("fingerprint cache: %w", err)
+	}
+
+	manifest := filepath.Join(dir, manifestFile)
+	if data, err := os.ReadFile(manifest); err != nil || string(data) != fingerprint {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(manifest, []byte(fingerprint), 0o644); err != nil {
+		return nil, err
+	}
+
+	return &Cache{dir: dir, used: make(map[string]struct{})}, nil
+}
+
+func Clear(cfg *config.Config) error {
+	return os.RemoveAll(Dir(cfg))
+}
+
+// Key hashes the given parts into a cache key.
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%d:", len(p))
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) Get(key string) (Entry, bool) {
+	if c == nil {
+		return Entry{}, false
+	}
+
+	c.markUsed(key)
+
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return Entry{}, false
+	}
+
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+func (c *Cache) Put(key string, e Entry) error {
+	if c == nil {
+		return nil
+	}
+
+	c.markUsed(key)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crashed build never leaves a
+	// truncated entry behind.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Prune removes every entry that was not read or written since the cache was
+// opened.
+func (c *Cache) Prune() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() == manifestFile {
+			return nil
+		}
+
+		key := strings.TrimSuffix(d.Name(), ".json")
+		if _, ok := c.used[key]; ok {
+			return nil
+		}
+		return os.Remove(path)
+	})
+}
+
+func (c *Cache) markUsed(key string) {
+	c.mu.Lock()
+	c.used[key] = struct{}{}
+	c.mu.Unlock()
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Fingerprint identifies everything outside the notes themselves that the
+// cached output depends on.
+func Fingerprint(cfg *config.Config) (string, error) {
+	h := sha256.New()
+
+	v := version.String()
+	fmt.Fprintf(h, "version:%s\n", v)
+
+	// Development builds share a version string, so tie them to the binary.
+	if !strings.Contains(v, "+") || strings.HasSuffix(v, "-dirty") {
+		if err := hashExecutable(h); err != nil {
+			return "", err
+		}
+	}
+
+	configData, err := os.ReadFile(config.ConfigFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	fmt.Fprintf(h, "config:%d:", len(configData))
+	h.Write(configData)
+
+	themeDir := filepath.Join("themes", cfg.Theme)
+	err = filepath.WalkDir(themeDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(themeDir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "theme:%s\n", filepath.ToSlash(rel))
+
+		return hashFile(h, path)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashExecutable(w io.Writer) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return hashFile(w, exe)
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
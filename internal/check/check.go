@@ -0,0 +1,213 @@
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"geode/internal/build"
+	"geode/internal/config"
+	"geode/internal/content"
+	"geode/internal/linkcheck"
+	"geode/internal/output"
+	"geode/internal/render"
+	"geode/internal/render/media"
+	"geode/internal/server"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Options struct {
+	// External also requests every link to another site.
+	External bool
+	// Client makes the requests of the external check, http.DefaultClient if
+	// nil.
+	Client *http.Client
+}
+
+// Run checks the notes of dir that a build would publish, without rendering
+// or writing anything. Notes with broken frontmatter are reported as well,
+// since a build silently skips them.
+func Run(ctx context.Context, dir string, cfg *config.Config, opts Options) ([]render.Problem, error) {
+	entries, err := content.GetAllMarkdownAndAssets(dir, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []render.Problem
+	for _, entry := range entries {
+		if !entry.IsMarkdown {
+			continue
+		}
+		if _, err := content.ReadFrontmatter(entry.Path); err != nil {
+			problems = append(problems, frontmatterProblem(entry, err))
+		}
+	}
+
+	providers, err := media.NewProviders(cfg.Embeds.Providers, cfg.Embeds.ClickToLoad)
+	if err != nil {
+		return nil, err
+	}
+
+	published := content.FilterEntries(entries, cfg)
+	pipeline := render.NewPipeline(published, content.Unpublished(entries, published), false, providers, cfg.Build.Jobs, nil)
+
+	unpublishedErrors := cfg.Build.UnpublishedLinks == config.UnpublishedError
+	problems = append(problems, pipeline.Check(published, unpublishedErrors)...)
+	problems = append(problems, checkOutput(dir, cfg, pipeline, published)...)
+
+	if opts.External {
+		external, err := checkExternal(ctx, pipeline.ExternalLinks(published), cfg, opts.Client)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, external...)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Path != problems[j].Path {
+			return problems[i].Path < problems[j].Path
+		}
+		return problems[i].Line < problems[j].Line
+	})
+
+	return problems, nil
+}
+
+// checkOutput writes the site the way a build does into a dry-run output and
+// reports every path two sources would write. Notes sharing a permalink are
+// left to Check, which reports them on both notes.
+func checkOutput(dir string, cfg *config.Config, pipeline *render.Pipeline, entries []content.FileEntry) []render.Problem {
+	pages := pipeline.Outline(entries)
+	out := output.NewDryRun()
+
+	writer, err := build.NewHTMLWriter(cfg, out)
+	if err == nil {
+		err = server.WriteSite(cfg, out, writer, entries, pages, false, render.BuildFileTree(pages))
+	}
+	if err != nil {
+		return []render.Problem{{Path: dir, Message: err.Error()}}
+	}
+
+	files := make(map[string]string) // source -> file
+	for _, entry := range entries {
+		files[entry.RelativePath] = entry.Path
+	}
+	notes := make(map[string]bool)
+	for _, page := range pages {
+		notes[page.RelativePath] = true
+		files["redirect to "+page.RelativePath] = page.Path
+	}
+
+	var problems []render.Problem
+	for _, c := range out.Collisions() {
+		if notes[c.Owner] && notes[c.Source] {
+			continue
+		}
+
+		msg := "build fails: " + c.Error()
+		if note, ok := strings.CutPrefix(c.Source, "redirect to "); ok {
+			msg = fmt.Sprintf("redirect from %s to %s is skipped: url is claimed by %s", c.URL, note, c.Owner)
+		}
+
+		path, ok := files[c.Source]
+		if !ok {
+			if path, ok = files[c.Owner]; !ok {
+				path = dir
+			}
+		}
+		problems = append(problems, render.Problem{Path: path, Message: msg})
+	}
+
+	return problems
+}
+
+// checkExternal requests the URL of every external link that is not in the
+// link cache and reports the broken ones.
+func checkExternal(ctx context.Context, links []render.ExternalLink, cfg *config.Config, client *http.Client) ([]render.Problem, error) {
+	opts := cfg.Check.External
+
+	linkCache, err := linkcheck.OpenCache(opts.Cache, opts.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("open link cache: %w", err)
+	}
+
+	urls := make([]string, len(links))
+	for i, link := range links {
+		urls[i] = link.URL
+	}
+
+	fmt.Fprintf(os.Stderr, "Checking %d external links...\n", len(urls))
+
+	checker := linkcheck.Checker{
+		Client:   client,
+		Cache:    linkCache,
+		Skip:     opts.Skip,
+		Interval: opts.Interval,
+	}
+	results := checker.Check(ctx, urls)
+
+	if err := linkCache.Save(); err != nil {
+		return nil, fmt.Errorf("save link cache: %w", err)
+	}
+
+	var problems []render.Problem
+	for _, link := range links {
+		if r, ok := results[link.URL]; ok && r.Broken() {
+			problems = append(problems, render.Problem{
+				Path:    link.Path,
+				Line:    link.Line,
+				Message: fmt.Sprintf("broken external link %q: %s", link.URL, r),
+			})
+		}
+	}
+
+	return problems, nil
+}
+
+func frontmatterProblem(entry content.FileEntry, err error) render.Problem {
+	var noteErr *content.NoteError
+	if errors.As(err, &noteErr) {
+		return render.Problem{Path: noteErr.Path, Line: noteErr.Line, Message: noteErr.Err.Error()}
+	}
+	return render.Problem{Path: entry.Path, Message: err.Error()}
+}
+
+// Write prints problems in format, one per line as path:line: message, or as
+// a JSON array.
+func Write(w io.Writer, problems []render.Problem, format string) error {
+	switch format {
+	case FormatText:
+		for _, p := range problems {
+			if _, err := fmt.Fprintln(w, p); err != nil {
+				return err
+			}
+		}
+		if len(problems) == 0 {
+			_, err := fmt.Fprintln(w, "No problems found.")
+			return err
+		}
+		_, err := fmt.Fprintf(w, "%d problems found.\n", len(problems))
+		return err
+
+	case FormatJSON:
+		if problems == nil {
+			problems = []render.Problem{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(problems)
+
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
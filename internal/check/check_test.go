@@ -0,0 +1,128 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"geode/internal/config"
+	"geode/internal/render"
+)
+
+func TestWrite(t *testing.T) {
+	problems := []render.Problem{
+		{Path: "vault/a.md", Line: 3, Message: `unresolved link [[Missing]]`},
+		{Path: "vault/b.md", Message: "yaml: did not find expected key"},
+	}
+
+	tests := []struct {
+		name     string
+		problems []render.Problem
+		format   string
+		want     string
+	}{
+		{"text", problems, FormatText, "vault/a.md:3: unresolved link [[Missing]]\nvault/b.md: yaml: did not find expected key\n2 problems found.\n"},
+		{"text without problems", nil, FormatText, "No problems found.\n"},
+		{"json", problems, FormatJSON, `[
+  {
+    "path": "vault/a.md",
+    "line": 3,
+    "message": "unresolved link [[Missing]]"
+  },
+  {
+    "path": "vault/b.md",
+    "message": "yaml: did not find expected key"
+  }
+]
+`},
+		{"json without problems", nil, FormatJSON, "[]\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, tt.problems, tt.format); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: output =\n%s\nwant\n%s", tt.name, buf.String(), tt.want)
+		}
+	}
+
+	if err := Write(&bytes.Buffer{}, problems, "xml"); err == nil {
+		t.Error("unknown format: no error")
+	}
+}
+
+// writeSite lays out a site with a minimal theme and files in its vault, and
+// makes it the working directory.
+func writeSite(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	t.Chdir(t.TempDir())
+
+	site := map[string]string{
+		"themes/test/templates/base.html": "{{.Title}}\n{{.Content}}\n",
+		"themes/test/templates/tag.html":  "{{.Tag}}\n",
+		"themes/test/templates/tags.html": "tags\n",
+		"themes/test/assets/style.css":    "body {}\n",
+	}
+	for rel, data := range files {
+		site[filepath.Join("vault", rel)] = data
+	}
+
+	for rel, data := range site {
+		if err := os.MkdirAll(filepath.Dir(rel), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(rel, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	writeSite(t, map[string]string{
+		"a.md":      "# A\n\nSee [[b#Part]] and [[Missing]].\n",
+		"b.md":      "---\naliases: [Old B]\n---\n# B\n",
+		"c.md":      "---\npermalink: /tags/go\n---\nC\n",
+		"d.md":      "#go\n",
+		"e.md":      "---\nredirect_from: [/a]\n---\nE\n",
+		"broken.md": "---\ntitle: [\n---\nBroken\n",
+	})
+
+	cfg := &config.Config{Theme: "test"}
+	cfg.Build.Mode = config.ModeDraft
+	problems, err := Run(context.Background(), "vault", cfg, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`vault/a.md:3: [[b#Part]]: no heading "Part" in b.md`,
+		`vault/a.md:3: unresolved link [[Missing]]`,
+		`vault/broken.md:2: frontmatter: yaml: line 1: did not find expected node content`,
+		`vault/c.md: build fails: url /tags/go is claimed by both c.md and tag #go`,
+		`vault/e.md: redirect from /a to e.md is skipped: url is claimed by a.md`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("problems =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, problems, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []render.Problem
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || !slices.Equal(decoded, problems) {
+		t.Errorf("JSON round trip = %v, %v", decoded, err)
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,6 +14,17 @@ type Social struct {
 	Link  string `yaml:"link"`
 }
 
+// EmbedProvider turns links to a site into embedded content. Template is an
+// html/template executed with the URL, the groups Pattern captured and the
+// size of the embed.
+type EmbedProvider struct {
+	Name     string `yaml:"name"`
+	Pattern  string `yaml:"pattern"`
+	Template string `yaml:"template"`
+	Width    int    `yaml:"width"`
+	Height   int    `yaml:"height"`
+}
+
 type Config struct {
 	Site struct {
 		Name    string `yaml:"name"`
@@ -22,8 +35,30 @@ type Config struct {
 	Build struct {
 		Output string `yaml:"output"`
 		Mode   string `yaml:"mode"`
+		Jobs   int    `yaml:"jobs"`
+		Cache  string `yaml:"cache"`
+
+		UnpublishedLinks string `yaml:"unpublished_links"`
 	} `yaml:"build"`
 
+	Check struct {
+		External struct {
+			Cache    string        `yaml:"cache"`
+			TTL      time.Duration `yaml:"ttl"`
+			Interval time.Duration `yaml:"interval"`
+			Skip     []string      `yaml:"skip"`
+		} `yaml:"external"`
+	} `yaml:"check"`
+
+	Content struct {
+		TextExtensions []string `yaml:"text_extensions"`
+	} `yaml:"content"`
+
+	Embeds struct {
+		ClickToLoad bool            `yaml:"click_to_load"`
+		Providers   []EmbedProvider `yaml:"providers"`
+	} `yaml:"embeds"`
+
 	Theme string `yaml:"theme"`
 
 	IgnorePatterns []string `yaml:"ignorePatterns"`
@@ -33,11 +68,33 @@ type Config struct {
 
 const ConfigFile = "geode.config.yaml"
 
+// DefaultLinkCache is where geode check --external remembers the external
+// links it checked.
+const DefaultLinkCache = ".geode-links.json"
+
+// DefaultTextExtensions are the code and text files notes can embed when
+// content.text_extensions is not set.
+var DefaultTextExtensions = []string{
+	".txt", ".log", ".diff", ".patch",
+	".go", ".py", ".rb", ".rs", ".c", ".h", ".cpp", ".hpp", ".cs", ".java", ".kt", ".swift",
+	".js", ".mjs", ".ts", ".jsx", ".tsx", ".lua", ".php", ".sql",
+	".sh", ".bash", ".zsh", ".fish", ".ps1",
+	".json", ".yaml", ".yml", ".toml", ".ini", ".conf", ".xml",
+	".css", ".scss",
+}
+
 const (
 	ModeDraft    = "draft"
 	ModeExplicit = "explicit"
 )
 
+// How links to notes left out of the site are rendered.
+const (
+	UnpublishedText    = "text"    // the link label as plain text
+	UnpublishedPrivate = "private" // the label marked as private
+	UnpublishedError   = "error"   // fail the build
+)
+
 func Load() (*Config, error) {
 	data, err := os.ReadFile(ConfigFile)
 	if err != nil {
@@ -57,6 +114,24 @@ func Load() (*Config, error) {
 		cfg.Theme = "default"
 	}
 
+	if cfg.Build.UnpublishedLinks == "" {
+		cfg.Build.UnpublishedLinks = UnpublishedText
+	}
+
+	if cfg.Content.TextExtensions == nil {
+		cfg.Content.TextExtensions = DefaultTextExtensions
+	}
+
+	if cfg.Check.External.Cache == "" {
+		cfg.Check.External.Cache = DefaultLinkCache
+	}
+	if cfg.Check.External.TTL == 0 {
+		cfg.Check.External.TTL = 24 * time.Hour
+	}
+	if cfg.Check.External.Interval == 0 {
+		cfg.Check.External.Interval = time.Second
+	}
+
 	return &cfg, nil
 }
 
@@ -73,6 +148,10 @@ func validate(cfg *Config) error {
 		return errors.New("build.output is required")
 	}
 
+	if cfg.Build.Jobs < 0 {
+		return errors.New("build.jobs must not be negative")
+	}
+
 	switch cfg.Build.Mode {
 	case ModeDraft, ModeExplicit:
 	// valid
@@ -80,5 +159,25 @@ func validate(cfg *Config) error {
 		return errors.New(`build.mode must be either "draft" or "explicit"`)
 	}
 
+	switch cfg.Build.UnpublishedLinks {
+	case "", UnpublishedText, UnpublishedPrivate, UnpublishedError:
+	// valid
+	default:
+		return errors.New(`build.unpublished_links must be "text", "private" or "error"`)
+	}
+
+	for _, p := range cfg.Embeds.Providers {
+		if p.Name == "" {
+			return errors.New("embeds.providers: every provider needs a name")
+		}
+		if p.Pattern != "" && p.Template == "" {
+			return fmt.Errorf("embeds.providers: %s has a pattern but no template", p.Name)
+		}
+	}
+
+	if cfg.Check.External.TTL < 0 || cfg.Check.External.Interval < 0 {
+		return errors.New("check.external.ttl and check.external.interval must not be negative")
+	}
+
 	return nil
 }
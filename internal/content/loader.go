@@ -8,6 +8,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -19,6 +21,7 @@ type FileEntry struct {
 	Size         int64
 	IsMarkdown   bool
 	IsAsset      bool
+	IsText       bool // a code or text file notes can embed, published only when embedded
 }
 
 func GetAllMarkdownAndAssets(srcDir string, cfg *config.Config) ([]FileEntry, error) {
@@ -43,9 +46,10 @@ func GetAllMarkdownAndAssets(srcDir string, cfg *config.Config) ([]FileEntry, er
 		ext := strings.ToLower(filepath.Ext(path))
 
 		isMarkdown := ext == ".md"
+		isText := !isMarkdown && isTextFile(ext, cfg.Content.TextExtensions)
 		isAsset := isAssetFile(ext)
 
-		if !isMarkdown && !isAsset {
+		if !isMarkdown && !isAsset && !isText {
 			return nil
 		}
 
@@ -65,6 +69,7 @@ func GetAllMarkdownAndAssets(srcDir string, cfg *config.Config) ([]FileEntry, er
 			Size:         info.Size(),
 			IsMarkdown:   isMarkdown,
 			IsAsset:      isAsset,
+			IsText:       isText,
 		})
 
 		return nil
@@ -105,8 +110,8 @@ func shouldSkip(root, path string, patterns []string) bool {
 
 var assetExt = map[string]struct{}{
 	".pdf": {}, ".csv": {},
-	".mp3": {}, ".wav": {}, ".ogg": {},
-	".mp4": {}, ".mov": {}, ".webm": {},
+	".mp3": {}, ".wav": {}, ".ogg": {}, ".m4a": {}, ".flac": {},
+	".mp4": {}, ".mov": {}, ".webm": {}, ".ogv": {},
 	".png": {}, ".jpg": {}, ".jpeg": {},
 	".gif": {}, ".svg": {}, ".webp": {},
 }
@@ -116,6 +121,37 @@ func isAssetFile(ext string) bool {
 	return ok
 }
 
+func isTextFile(ext string, textExt []string) bool {
+	for _, e := range textExt {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e != "" && "."+strings.TrimPrefix(e, ".") == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// NoteError is an error in a single note. Line is 1-based and zero when
+// unknown.
+type NoteError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (e *NoteError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *NoteError) Unwrap() error {
+	return e.Err
+}
+
+var yamlLine = regexp.MustCompile(`line (\d+):`)
+
 type RawNoteMeta struct {
 	Title      string   `yaml:"title"`
 	Publish    bool     `yaml:"publish"`
@@ -157,7 +193,14 @@ func ReadFrontmatter(path string) (*RawNoteMeta, error) {
 	var meta RawNoteMeta
 
 	if err := yaml.Unmarshal([]byte(strings.Join(yamlLines, "\n")), &meta); err != nil {
-		return nil, fmt.Errorf("frontmatter yaml error in %s: %w", path, err)
+		noteErr := &NoteError{Path: path, Err: fmt.Errorf("frontmatter: %w", err)}
+		// yaml counts lines from the start of the frontmatter, after the
+		// opening delimiter.
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			noteErr.Line = line + 1
+		}
+		return nil, noteErr
 	}
 
 	return &meta, nil
@@ -200,3 +243,20 @@ func FilterEntries(entries []FileEntry, cfg *config.Config) []FileEntry {
 
 	return out
 }
+
+// Unpublished returns the notes of entries that FilterEntries left out of
+// published.
+func Unpublished(entries, published []FileEntry) []FileEntry {
+	keep := make(map[string]struct{}, len(published))
+	for _, entry := range published {
+		keep[entry.Path] = struct{}{}
+	}
+
+	var out []FileEntry
+	for _, entry := range entries {
+		if _, ok := keep[entry.Path]; !ok && entry.IsMarkdown {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
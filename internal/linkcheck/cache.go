@@ -0,0 +1,99 @@
+package linkcheck
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache remembers the results of checked URLs on disk for TTL, so that
+// repeated checks do not hit every site again.
+type Cache struct {
+	path string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	results map[string]Result
+}
+
+// OpenCache loads the cache stored at path. A missing or unreadable file
+// starts an empty cache.
+func OpenCache(path string, ttl time.Duration) (*Cache, error) {
+	c := &Cache{path: path, ttl: ttl, results: make(map[string]Result)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &c.results); err != nil {
+		c.results = make(map[string]Result)
+	}
+	return c, nil
+}
+
+// Get returns the result for url if it was checked within the TTL.
+func (c *Cache) Get(url string) (Result, bool) {
+	if c == nil {
+		return Result{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.results[url]
+	if !ok || time.Since(r.Checked) > c.ttl {
+		return Result{}, false
+	}
+	return r, true
+}
+
+func (c *Cache) Put(url string, r Result) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.results[url] = r
+	c.mu.Unlock()
+}
+
+// Save writes the cache back to disk, dropping the expired results.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for url, r := range c.results {
+		if time.Since(r.Checked) > c.ttl {
+			delete(c.results, url)
+		}
+	}
+
+	data, err := json.MarshalIndent(c.results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(c.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
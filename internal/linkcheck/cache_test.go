@@ -0,0 +1,85 @@
+package linkcheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "links.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Put("https://a.test/new", Result{Status: 200, Checked: time.Now()})
+	cache.Put("https://a.test/old", Result{Status: 200, Checked: time.Now().Add(-time.Hour - time.Minute)})
+
+	if _, ok := cache.Get("https://a.test/new"); !ok {
+		t.Error("result within the TTL is missing")
+	}
+	if _, ok := cache.Get("https://a.test/old"); ok {
+		t.Error("expired result is returned")
+	}
+	if _, ok := cache.Get("https://a.test/none"); ok {
+		t.Error("unknown URL is returned")
+	}
+
+	var nilCache *Cache
+	nilCache.Put("https://a.test/new", Result{Status: 200, Checked: time.Now()})
+	if _, ok := nilCache.Get("https://a.test/new"); ok {
+		t.Error("nil cache returned a result")
+	}
+	if err := nilCache.Save(); err != nil {
+		t.Errorf("nil cache: Save() = %v", err)
+	}
+}
+
+func TestCacheSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "links.json")
+
+	cache, err := OpenCache(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("https://a.test/new", Result{Status: 404, Checked: time.Now()})
+	cache.Put("https://a.test/old", Result{Status: 404, Checked: time.Now().Add(-2 * time.Hour)})
+
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A longer TTL would bring the expired result back if it was saved.
+	reopened, err := OpenCache(path, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := reopened.Get("https://a.test/new"); !ok || r.Status != 404 {
+		t.Errorf("saved result = %v, %v, want 404", r, ok)
+	}
+	if _, ok := reopened.Get("https://a.test/old"); ok {
+		t.Error("expired result was saved")
+	}
+}
+
+func TestOpenCache(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := OpenCache(filepath.Join(dir, "missing.json"), time.Hour)
+	if err != nil || cache == nil {
+		t.Fatalf("missing file: OpenCache() = %v, %v", cache, err)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache, err = OpenCache(corrupt, time.Hour)
+	if err != nil {
+		t.Fatalf("corrupt file: OpenCache() error = %v", err)
+	}
+	if _, ok := cache.Get("https://a.test/"); ok {
+		t.Error("corrupt file: cache is not empty")
+	}
+}
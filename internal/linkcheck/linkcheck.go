@@ -0,0 +1,211 @@
+package linkcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJobs    = 8
+	defaultTimeout = 15 * time.Second
+	userAgent      = "Mozilla/5.0 (compatible; geode-linkcheck)"
+)
+
+// Result is the outcome of requesting an external URL.
+type Result struct {
+	Status  int       `json:"status,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+// Broken reports whether the URL is gone. A server asking to slow down says
+// nothing about the URL, so it is not broken.
+func (r Result) Broken() bool {
+	if r.Error != "" {
+		return true
+	}
+	return r.Status >= 400 && r.Status != http.StatusTooManyRequests
+}
+
+func (r Result) String() string {
+	if r.Error != "" {
+		return r.Error
+	}
+	return fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status))
+}
+
+// Checker requests external URLs to find the broken ones. Requests run
+// concurrently, but the requests to a single host are spaced out by
+// Interval.
+type Checker struct {
+	Client   *http.Client  // http.DefaultClient if nil
+	Cache    *Cache        // may be nil
+	Skip     []string      // hosts that are never requested, with their subdomains
+	Jobs     int           // requests in flight at once, 8 if zero
+	Interval time.Duration // between two requests to the same host
+	Timeout  time.Duration // per request, 15s if zero
+}
+
+// Check requests every URL not in the cache and returns the result of every
+// URL that was not skipped.
+func (c *Checker) Check(ctx context.Context, urls []string) map[string]Result {
+	results := make(map[string]Result, len(urls))
+	byHost := make(map[string][]string)
+	seen := make(map[string]struct{}, len(urls))
+
+	for _, raw := range urls {
+		if _, ok := seen[raw]; ok {
+			continue
+		}
+		seen[raw] = struct{}{}
+
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			results[raw] = Result{Error: "invalid URL", Checked: time.Now()}
+			continue
+		}
+
+		host := strings.ToLower(u.Hostname())
+		if c.skip(host) {
+			continue
+		}
+
+		if r, ok := c.Cache.Get(raw); ok {
+			results[raw] = r
+			continue
+		}
+
+		byHost[host] = append(byHost[host], raw)
+	}
+
+	jobs := c.Jobs
+	if jobs <= 0 {
+		jobs = defaultJobs
+	}
+	sem := make(chan struct{}, jobs)
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, hostURLs := range byHost {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i, raw := range hostURLs {
+				if i > 0 && !sleep(ctx, c.Interval) {
+					return
+				}
+
+				r, ok := c.fetch(ctx, sem, raw)
+				if !ok {
+					return
+				}
+
+				mu.Lock()
+				results[raw] = r
+				mu.Unlock()
+
+				// Only a response is worth remembering, errors may be
+				// on our side.
+				if r.Error == "" && r.Status != http.StatusTooManyRequests {
+					c.Cache.Put(raw, r)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (c *Checker) skip(host string) bool {
+	for _, skip := range c.Skip {
+		skip = strings.ToLower(strings.TrimSpace(skip))
+		if skip != "" && (host == skip || strings.HasSuffix(host, "."+skip)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetch tries a HEAD request first, and falls back to GET for the many
+// servers that do not answer HEAD properly. A server asking to slow down is
+// not asked again right away. The GET is one more request to the host, so it
+// waits out the interval first. fetch reports false if ctx is cancelled
+// while waiting.
+func (c *Checker) fetch(ctx context.Context, sem chan struct{}, raw string) (Result, bool) {
+	status, err := c.request(ctx, sem, http.MethodHead, raw)
+	if err != nil || (status >= 400 && status != http.StatusTooManyRequests) {
+		if !sleep(ctx, c.Interval) {
+			return Result{}, false
+		}
+		status, err = c.request(ctx, sem, http.MethodGet, raw)
+	}
+
+	r := Result{Status: status, Checked: time.Now()}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r, true
+}
+
+// request holds a slot in sem while it runs, so that waiting between
+// requests does not keep other hosts waiting.
+func (c *Checker) request(ctx context.Context, sem chan struct{}, method, raw string) (int, error) {
+	sem <- struct{}{}
+	defer func() { <-sem }()
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, raw, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.CopyN(io.Discard, res.Body, 64<<10)
+
+	return res.StatusCode, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
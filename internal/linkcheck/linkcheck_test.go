@@ -0,0 +1,265 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder serves every request with handler, whatever its host, and
+// remembers when each one arrived.
+type recorder struct {
+	handler http.HandlerFunc
+
+	mu   sync.Mutex
+	reqs []recorded
+}
+
+type recorded struct {
+	method, host, path string
+	at                 time.Time
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.reqs = append(r.reqs, recorded{req.Method, req.URL.Hostname(), req.URL.Path, time.Now()})
+	r.mu.Unlock()
+
+	w := httptest.NewRecorder()
+	if r.handler != nil {
+		r.handler(w, req)
+	}
+	return w.Result(), nil
+}
+
+func (r *recorder) requests(host string) []recorded {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var reqs []recorded
+	for _, req := range r.reqs {
+		if host == "" || req.host == host {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+func TestResultBroken(t *testing.T) {
+	tests := []struct {
+		r    Result
+		want bool
+	}{
+		{Result{Status: 200}, false},
+		{Result{Status: 301}, false},
+		{Result{Status: 403}, true},
+		{Result{Status: 404}, true},
+		{Result{Status: 429}, false},
+		{Result{Status: 500}, true},
+		{Result{Error: "connection refused"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Broken(); got != tt.want {
+			t.Errorf("%v: Broken() = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestCheckFetch(t *testing.T) {
+	tests := []struct {
+		name    string
+		head    int
+		get     int
+		status  int
+		broken  bool
+		methods []string
+		cached  bool
+	}{
+		{"head ok", 200, 500, 200, false, []string{"HEAD"}, true},
+		{"head not allowed", 405, 200, 200, false, []string{"HEAD", "GET"}, true},
+		{"not found", 404, 404, 404, true, []string{"HEAD", "GET"}, true},
+		{"too many requests", 429, 200, 429, false, []string{"HEAD"}, false},
+		{"too many requests on get", 405, 429, 429, false, []string{"HEAD", "GET"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				methods []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				methods = append(methods, r.Method)
+				mu.Unlock()
+
+				if r.Method == http.MethodHead {
+					w.WriteHeader(tt.head)
+				} else {
+					w.WriteHeader(tt.get)
+				}
+			}))
+			defer srv.Close()
+
+			cache, err := OpenCache(t.TempDir()+"/links.json", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := &Checker{Client: srv.Client(), Cache: cache}
+			results := c.Check(context.Background(), []string{srv.URL + "/page"})
+
+			r := results[srv.URL+"/page"]
+			if r.Status != tt.status || r.Broken() != tt.broken {
+				t.Errorf("result = %v (broken %v), want %d (broken %v)", r, r.Broken(), tt.status, tt.broken)
+			}
+			if !slices.Equal(methods, tt.methods) {
+				t.Errorf("methods = %v, want %v", methods, tt.methods)
+			}
+			if _, ok := cache.Get(srv.URL + "/page"); ok != tt.cached {
+				t.Errorf("cached = %v, want %v", ok, tt.cached)
+			}
+		})
+	}
+}
+
+func TestCheckError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL + "/page"
+	srv.Close()
+
+	cache, err := OpenCache(t.TempDir()+"/links.json", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Cache: cache, Timeout: time.Second}
+	results := c.Check(context.Background(), []string{url, "not a url"})
+
+	if r := results[url]; r.Error == "" || !r.Broken() {
+		t.Errorf("closed server: result = %v, want an error", r)
+	}
+	if r := results["not a url"]; r.Error != "invalid URL" {
+		t.Errorf("invalid URL: result = %v", r)
+	}
+	if _, ok := cache.Get(url); ok {
+		t.Error("errors are cached")
+	}
+}
+
+func TestCheckInterval(t *testing.T) {
+	rec := &recorder{}
+	c := &Checker{
+		Client:   &http.Client{Transport: rec},
+		Interval: 200 * time.Millisecond,
+	}
+
+	start := time.Now()
+	results := c.Check(context.Background(), []string{
+		"https://a.test/1",
+		"https://a.test/2",
+		"https://b.test/1",
+	})
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	a := rec.requests("a.test")
+	if len(a) != 2 {
+		t.Fatalf("got %d requests to a.test, want 2", len(a))
+	}
+	if gap := a[1].at.Sub(a[0].at); gap < c.Interval {
+		t.Errorf("requests to the same host %v apart, want at least %v", gap, c.Interval)
+	}
+
+	b := rec.requests("b.test")
+	if len(b) != 1 {
+		t.Fatalf("got %d requests to b.test, want 1", len(b))
+	}
+	if wait := b[0].at.Sub(start); wait >= c.Interval {
+		t.Errorf("request to another host waited %v", wait)
+	}
+}
+
+func TestCheckIntervalBeforeGet(t *testing.T) {
+	rec := &recorder{handler: func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}}
+	c := &Checker{
+		Client:   &http.Client{Transport: rec},
+		Interval: 200 * time.Millisecond,
+	}
+
+	url := "https://a.test/page"
+	if r := c.Check(context.Background(), []string{url})[url]; r.Status != http.StatusOK {
+		t.Fatalf("result = %v, want status 200", r)
+	}
+
+	reqs := rec.requests("a.test")
+	if len(reqs) != 2 || reqs[0].method != http.MethodHead || reqs[1].method != http.MethodGet {
+		t.Fatalf("requests = %v, want HEAD then GET", reqs)
+	}
+	if gap := reqs[1].at.Sub(reqs[0].at); gap < c.Interval {
+		t.Errorf("GET followed HEAD after %v, want at least %v", gap, c.Interval)
+	}
+}
+
+func TestCheckSkip(t *testing.T) {
+	rec := &recorder{}
+	c := &Checker{
+		Client: &http.Client{Transport: rec},
+		Skip:   []string{" Example.com ", ""},
+	}
+
+	results := c.Check(context.Background(), []string{
+		"https://example.com/a",
+		"https://www.example.com/b",
+		"https://deep.sub.EXAMPLE.com/c",
+		"https://notexample.com/d",
+		"https://example.com.test/e",
+	})
+
+	var hosts []string
+	for _, req := range rec.requests("") {
+		hosts = append(hosts, req.host)
+	}
+	slices.Sort(hosts)
+	if want := []string{"example.com.test", "notexample.com"}; !slices.Equal(hosts, want) {
+		t.Errorf("requested %v, want %v", hosts, want)
+	}
+	if len(results) != 2 {
+		t.Errorf("got %d results, want 2", len(results))
+	}
+}
+
+func TestCheckUsesCache(t *testing.T) {
+	rec := &recorder{}
+	cache, err := OpenCache(t.TempDir()+"/links.json", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("https://a.test/fresh", Result{Status: 404, Checked: time.Now()})
+	cache.Put("https://a.test/stale", Result{Status: 404, Checked: time.Now().Add(-2 * time.Hour)})
+
+	c := &Checker{Client: &http.Client{Transport: rec}, Cache: cache}
+	results := c.Check(context.Background(), []string{"https://a.test/fresh", "https://a.test/stale"})
+
+	if r := results["https://a.test/fresh"]; r.Status != 404 {
+		t.Errorf("fresh: result = %v, want the cached 404", r)
+	}
+	if r := results["https://a.test/stale"]; r.Status != 200 {
+		t.Errorf("stale: result = %v, want 200", r)
+	}
+
+	reqs := rec.requests("")
+	if len(reqs) != 1 || reqs[0].path != "/stale" {
+		t.Errorf("requests = %v, want one to /stale", reqs)
+	}
+}
@@ -0,0 +1,126 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Disk writes the site into a directory.
+type Disk struct {
+	claims
+
+	dir    string
+	target string // set on staged outputs
+}
+
+func NewDisk(dir string) *Disk {
+	return &Disk{dir: filepath.Clean(dir)}
+}
+
+func (d *Disk) Dir() string {
+	return d.dir
+}
+
+// Path maps a slash separated path relative to the output root to a path on
+// disk.
+func (d *Disk) Path(rel string) string {
+	return filepath.Join(d.dir, filepath.FromSlash(rel))
+}
+
+func (d *Disk) FS() fs.FS {
+	return os.DirFS(d.dir)
+}
+
+func (d *Disk) Create(rel string) (io.WriteCloser, error) {
+	if err := CheckPath(rel); err != nil {
+		return nil, err
+	}
+
+	path := d.Path(rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	return os.Create(path)
+}
+
+func (d *Disk) WriteFile(rel string, data []byte) error {
+	return writeFile(d, rel, data)
+}
+
+func (d *Disk) CopyFile(srcFile, rel string) error {
+	return copyFile(d, srcFile, rel)
+}
+
+func (d *Disk) CopyDir(srcDir, rel string) error {
+	return copyDir(d, srcDir, rel)
+}
+
+// Stage writes into a fresh staging directory next to d, which Commit swaps
+// into place.
+func (d *Disk) Stage() (Output, error) {
+	parent := filepath.Dir(d.dir)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return nil, err
+	}
+
+	prefix := "." + filepath.Base(d.dir) + ".staging-"
+
+	// Leftovers of builds that were interrupted.
+	stale, _ := filepath.Glob(filepath.Join(parent, prefix+"*"))
+	for _, dir := range stale {
+		_ = os.RemoveAll(dir)
+	}
+
+	dir, err := os.MkdirTemp(parent, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Disk{dir: dir, target: d.dir}, nil
+}
+
+func (d *Disk) Commit() error {
+	if d.target == "" {
+		return nil
+	}
+
+	if err := swapDir(d.dir, d.target); err != nil {
+		return fmt.Errorf("swap %s into %s: %w", d.dir, d.target, err)
+	}
+
+	d.dir, d.target = d.target, ""
+	return nil
+}
+
+func (d *Disk) Discard() error {
+	if d.target == "" {
+		return nil
+	}
+	return os.RemoveAll(d.dir)
+}
+
+// renameSwap moves staging to target, keeping target's old content around
+// until the new one is in place.
+func renameSwap(staging, target string) error {
+	if _, err := os.Stat(target); os.IsNotExist(err) {
+		return os.Rename(staging, target)
+	}
+
+	old := staging + ".old"
+	if err := os.Rename(target, old); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, target); err != nil {
+		_ = os.Rename(old, target)
+		return err
+	}
+
+	return os.RemoveAll(old)
+}
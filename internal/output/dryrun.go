@@ -0,0 +1,85 @@
+package output
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// DryRun claims paths like a real output but discards everything written to
+// it, so a build can be checked for paths it would fail on. Its Claim never
+// fails: collisions are kept for Collisions, and paths outside the output
+// directory are ignored, since CheckPath reports them on its own.
+type DryRun struct {
+	claims
+
+	mu         sync.Mutex
+	collisions []*ClaimError
+}
+
+func NewDryRun() *DryRun {
+	return &DryRun{}
+}
+
+func (d *DryRun) Claim(rel, source string) error {
+	if CheckPath(rel) != nil {
+		return nil
+	}
+
+	var claimErr *ClaimError
+	if err := d.claims.Claim(rel, source); errors.As(err, &claimErr) {
+		d.mu.Lock()
+		d.collisions = append(d.collisions, claimErr)
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+// Collisions returns every claim that collided with an earlier one, in claim
+// order.
+func (d *DryRun) Collisions() []*ClaimError {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.collisions
+}
+
+func (d *DryRun) Create(rel string) (io.WriteCloser, error) {
+	return nopWriteCloser{io.Discard}, nil
+}
+
+func (d *DryRun) WriteFile(rel string, data []byte) error {
+	return nil
+}
+
+func (d *DryRun) CopyFile(srcFile, rel string) error {
+	return nil
+}
+
+func (d *DryRun) CopyDir(srcDir, rel string) error {
+	return copyDir(d, srcDir, rel)
+}
+
+func (d *DryRun) Stage() (Output, error) {
+	return d, nil
+}
+
+func (d *DryRun) Commit() error {
+	return nil
+}
+
+func (d *DryRun) Discard() error {
+	return nil
+}
+
+func (d *DryRun) FS() fs.FS {
+	return memFS{&memStore{files: make(map[string]memFile)}}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
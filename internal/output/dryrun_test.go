@@ -0,0 +1,49 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	theme := t.TempDir()
+	if err := os.WriteFile(filepath.Join(theme, "tags.html"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDryRun()
+	claims := []struct{ rel, source string }{
+		{"notes/a.html", "notes/a.md"},
+		{"notes/a.html", "notes/a.md"},
+		{"tags.html", "tags index"},
+		{"notes/a.html", "redirect to b.md"},
+		{"../escape.html", "c.md"},
+	}
+	for _, c := range claims {
+		if err := d.Claim(c.rel, c.source); err != nil {
+			t.Errorf("Claim(%s, %s) = %v", c.rel, c.source, err)
+		}
+	}
+	if err := d.CopyDir(theme, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ClaimError{
+		{URL: "/notes/a", Owner: "notes/a.md", Source: "redirect to b.md"},
+		{URL: "/tags", Owner: "tags index", Source: filepath.ToSlash(filepath.Join(theme, "tags.html"))},
+	}
+	got := d.Collisions()
+	if len(got) != len(want) {
+		t.Fatalf("got %d collisions, want %d: %v", len(got), len(want), got)
+	}
+	for i, c := range got {
+		if *c != want[i] {
+			t.Errorf("collision %d = %+v, want %+v", i, *c, want[i])
+		}
+	}
+
+	if _, err := d.FS().Open("tags.html"); err == nil {
+		t.Error("dry run kept a copied file")
+	}
+}
@@ -0,0 +1,211 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Memory keeps the site in memory, for serving it without touching disk.
+// Files are replaced as a whole when their writer is closed, so readers never
+// observe a partially written file.
+type Memory struct {
+	claims
+
+	store  atomic.Pointer[memStore]
+	root   *Memory // the published output this one writes into
+	staged bool
+}
+
+type memStore struct {
+	mu    sync.RWMutex
+	files map[string]memFile
+}
+
+type memFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemory() *Memory {
+	m := &Memory{}
+	m.root = m
+	m.store.Store(&memStore{files: make(map[string]memFile)})
+	return m
+}
+
+func (m *Memory) FS() fs.FS {
+	return memFS{m.store.Load()}
+}
+
+func (m *Memory) Create(rel string) (io.WriteCloser, error) {
+	if err := CheckPath(rel); err != nil {
+		return nil, err
+	}
+	return &memWriter{store: m.store.Load(), rel: cleanPath(rel)}, nil
+}
+
+func (m *Memory) WriteFile(rel string, data []byte) error {
+	return writeFile(m, rel, data)
+}
+
+func (m *Memory) CopyFile(srcFile, rel string) error {
+	return copyFile(m, srcFile, rel)
+}
+
+func (m *Memory) CopyDir(srcDir, rel string) error {
+	return copyDir(m, srcDir, rel)
+}
+
+// Stage writes into an empty store that replaces m's store on Commit.
+func (m *Memory) Stage() (Output, error) {
+	staged := NewMemory()
+	staged.root = m.root
+	staged.staged = true
+	return staged, nil
+}
+
+func (m *Memory) Commit() error {
+	if !m.staged {
+		return nil
+	}
+
+	m.root.store.Store(m.store.Load())
+	m.staged = false
+	return nil
+}
+
+func (m *Memory) Discard() error {
+	return nil
+}
+
+type memWriter struct {
+	bytes.Buffer
+	store *memStore
+	rel   string
+}
+
+func (w *memWriter) Close() error {
+	data := bytes.Clone(w.Bytes())
+
+	w.store.mu.Lock()
+	w.store.files[w.rel] = memFile{data: data, modTime: time.Now()}
+	w.store.mu.Unlock()
+	return nil
+}
+
+type memFS struct {
+	store *memStore
+}
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	if f, ok := m.store.files[name]; ok {
+		return &memOpenFile{
+			Reader: bytes.NewReader(f.data),
+			info:   memInfo{name: path.Base(name), size: int64(len(f.data)), modTime: f.modTime},
+		}, nil
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	for p, f := range m.store.files {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok {
+			continue
+		}
+
+		child, _, isDir := strings.Cut(rest, "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+
+		info := memInfo{name: child, size: int64(len(f.data)), modTime: f.modTime, dir: isDir}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+
+	if len(entries) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return &memDir{info: memInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+type memOpenFile struct {
+	*bytes.Reader
+	info memInfo
+}
+
+func (f *memOpenFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memOpenFile) Close() error               { return nil }
+
+type memDir struct {
+	info    memInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() any           { return nil }
+
+func (i memInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
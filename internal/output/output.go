@@ -0,0 +1,172 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"geode/internal/config"
+)
+
+// Output is where every stage of the build writes the site. Paths are slash
+// separated and relative to the site root.
+type Output interface {
+	// Claim records source as the owner of the output path rel. It fails
+	// when another source already claimed the same path, since one of them
+	// would silently overwrite the other.
+	Claim(rel, source string) error
+
+	Create(rel string) (io.WriteCloser, error)
+	WriteFile(rel string, data []byte) error
+	CopyFile(srcFile, rel string) error
+	CopyDir(srcDir, rel string) error
+
+	// Stage returns an output that is only published once committed, so the
+	// previous output stays intact while a build runs and after it fails.
+	Stage() (Output, error)
+	// Commit publishes a staged output. Afterwards writes go directly to the
+	// published site.
+	Commit() error
+	// Discard drops an uncommitted staged output.
+	Discard() error
+
+	// FS exposes the currently published site.
+	FS() fs.FS
+}
+
+// New returns the output configured for real builds: the build.output
+// directory on disk.
+func New(cfg *config.Config) Output {
+	return NewDisk(cfg.Build.Output)
+}
+
+// PagePath maps a page URL to the HTML file it is written to.
+func PagePath(url string) string {
+	rel := strings.Trim(url, "/")
+	if rel == "" {
+		rel = "index"
+	}
+	return rel + ".html"
+}
+
+type claims struct {
+	mu     sync.Mutex
+	owners map[string]string // output path -> source
+}
+
+func (c *claims) Claim(rel, source string) error {
+	if err := CheckPath(rel); err != nil {
+		return err
+	}
+	rel = cleanPath(rel)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.owners == nil {
+		c.owners = make(map[string]string)
+	}
+
+	if owner, ok := c.owners[rel]; ok && owner != source {
+		return &ClaimError{URL: urlFor(rel), Owner: owner, Source: source}
+	}
+	c.owners[rel] = source
+	return nil
+}
+
+// ClaimError reports a path claimed by a second source.
+type ClaimError struct {
+	URL    string
+	Owner  string // the source that claimed the path first
+	Source string
+}
+
+func (e *ClaimError) Error() string {
+	return fmt.Sprintf("url %s is claimed by both %s and %s", e.URL, e.Owner, e.Source)
+}
+
+func urlFor(rel string) string {
+	if rel == "index.html" {
+		return "/"
+	}
+	return "/" + strings.TrimSuffix(rel, ".html")
+}
+
+// CheckPath fails when rel, once cleaned, leads out of the output root, as a
+// permalink such as ../../etc/page would.
+func CheckPath(rel string) error {
+	clean := path.Clean(strings.TrimPrefix(filepath.ToSlash(rel), "/"))
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("path %s is outside the output directory", rel)
+	}
+	return nil
+}
+
+func cleanPath(rel string) string {
+	rel = filepath.ToSlash(filepath.Clean(filepath.FromSlash(rel)))
+	return strings.TrimPrefix(rel, "/")
+}
+
+func writeFile(o Output, rel string, data []byte) error {
+	f, err := o.Create(rel)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func copyFile(o Output, srcFile, rel string) error {
+	src, err := os.Open(srcFile)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := o.Create(rel)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dest, src); err != nil {
+		_ = dest.Close()
+		return err
+	}
+
+	return dest.Close()
+}
+
+func copyDir(o Output, srcDir, rel string) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		sub, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.ToSlash(filepath.Join(rel, sub))
+
+		if err := o.Claim(target, filepath.ToSlash(path)); err != nil {
+			return err
+		}
+
+		return o.CopyFile(path, target)
+	})
+}
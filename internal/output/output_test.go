@@ -0,0 +1,202 @@
+package output
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClaim(t *testing.T) {
+	tests := []struct {
+		rel, source string
+		wantErr     string
+	}{
+		{"a.html", "a.md", ""},
+		{"a.html", "a.md", ""},
+		{"./a.html", "b.md", "url /a is claimed by both a.md and b.md"},
+		{"index.html", "index.md", ""},
+		{"/index.html", "home.md", "url / is claimed by both index.md and home.md"},
+		{"dir/../tags.html", "tags index", ""},
+		{"tags.html", "tag #x", "url /tags is claimed by both tags index and tag #x"},
+		{"../escape.html", "c.md", "outside the output directory"},
+	}
+
+	for _, out := range []Output{NewDisk(t.TempDir()), NewMemory()} {
+		for _, tt := range tests {
+			err := out.Claim(tt.rel, tt.source)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("%T: Claim(%s, %s) = %v", out, tt.rel, tt.source, err)
+				}
+				continue
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%T: Claim(%s, %s) = %v, want %s", out, tt.rel, tt.source, err, tt.wantErr)
+			}
+		}
+	}
+
+	m := NewMemory()
+	if err := m.Claim("x.html", "a"); err != nil {
+		t.Fatal(err)
+	}
+	var claimErr *ClaimError
+	if err := m.Claim("x.html", "b"); !errors.As(err, &claimErr) || claimErr.Owner != "a" || claimErr.Source != "b" {
+		t.Errorf("Claim() = %v, want a ClaimError", err)
+	}
+}
+
+func TestDiskStage(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "public")
+	d := NewDisk(dir)
+
+	if err := d.WriteFile("old.html", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Left behind by an interrupted build.
+	stale := filepath.Join(parent, ".public.staging-123")
+	if err := os.MkdirAll(stale, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := d.Stage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale staging directory survived: %v", err)
+	}
+
+	if err := staged.WriteFile("new.html", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, d.FS(), "old.html")
+
+	if err := staged.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, os.DirFS(dir), "new.html")
+
+	// Writes after Commit go to the published site.
+	if err := staged.WriteFile("later.html", []byte("later")); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, os.DirFS(dir), "later.html", "new.html")
+
+	leftovers, _ := filepath.Glob(filepath.Join(parent, ".public.staging-*"))
+	if len(leftovers) > 0 {
+		t.Errorf("staging directories left after Commit: %v", leftovers)
+	}
+}
+
+func TestDiskStageFirstBuild(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "site", "public")
+
+	staged, err := NewDisk(dir).Stage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := staged.WriteFile("index.html", []byte("home")); err != nil {
+		t.Fatal(err)
+	}
+	if err := staged.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, os.DirFS(dir), "index.html")
+}
+
+func TestDiskDiscard(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "public")
+	d := NewDisk(dir)
+	if err := d.WriteFile("old.html", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := d.Stage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := staged.WriteFile("new.html", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := staged.Discard(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFiles(t, d.FS(), "old.html")
+	leftovers, _ := filepath.Glob(filepath.Join(parent, ".public.staging-*"))
+	if len(leftovers) > 0 {
+		t.Errorf("staging directories left after Discard: %v", leftovers)
+	}
+}
+
+func TestMemoryStage(t *testing.T) {
+	m := NewMemory()
+	if err := m.WriteFile("old.html", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := m.Stage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"a.html", "dir/b.html"} {
+		if err := staged.WriteFile(rel, []byte(rel)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Until Commit, readers see the previous site only, however far the
+	// staged build got.
+	before := m.FS()
+	assertFiles(t, before, "old.html")
+
+	if err := staged.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, m.FS(), "a.html", "dir/b.html")
+	assertFiles(t, before, "old.html")
+
+	if err := staged.WriteFile("later.html", []byte("later")); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, m.FS(), "a.html", "dir/b.html", "later.html")
+
+	failed, err := m.Stage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := failed.WriteFile("broken.html", []byte("broken")); err != nil {
+		t.Fatal(err)
+	}
+	if err := failed.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, m.FS(), "a.html", "dir/b.html", "later.html")
+}
+
+// assertFiles checks that fsys holds exactly the files want, in walk order.
+func assertFiles(t *testing.T, fsys fs.FS, want ...string) {
+	t.Helper()
+
+	var got []string
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			got = append(got, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package output
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// swapDir atomically exchanges staging and target, so readers of target
+// never observe a missing or half-written directory.
+func swapDir(staging, target string) error {
+	if _, err := os.Stat(target); os.IsNotExist(err) {
+		return os.Rename(staging, target)
+	}
+
+	err := unix.Renameat2(unix.AT_FDCWD, staging, unix.AT_FDCWD, target, unix.RENAME_EXCHANGE)
+	if err == unix.ENOSYS || err == unix.EINVAL {
+		// Kernel or filesystem without RENAME_EXCHANGE support.
+		return renameSwap(staging, target)
+	}
+	if err != nil {
+		return err
+	}
+
+	// staging now holds the previous output.
+	return os.RemoveAll(staging)
+}
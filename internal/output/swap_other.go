@@ -0,0 +1,7 @@
+//go:build !linux
+
+package output
+
+func swapDir(staging, target string) error {
+	return renameSwap(staging, target)
+}
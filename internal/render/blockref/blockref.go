@@ -0,0 +1,94 @@
+package blockref
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	trailingID = regexp.MustCompile(`(?:^|[ \t])\^([A-Za-z0-9-]+)[ \t]*$`)
+	standalone = regexp.MustCompile(`^\^([A-Za-z0-9-]+)$`)
+)
+
+// ID returns the element id of the block identified by id, given without
+// the leading caret.
+func ID(id string) string {
+	return "^" + id
+}
+
+// Valid reports whether id, given without the leading caret, is a block
+// identifier.
+func Valid(id string) bool {
+	return standalone.MatchString("^" + id)
+}
+
+type Extender struct{}
+
+func (e *Extender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(&Transformer{}, 500),
+		),
+	)
+}
+
+// Transformer assigns Obsidian block identifiers. A paragraph or list item
+// ending in "^id" gets the id "^id", and so does the block right before a line
+// holding nothing but "^id", which is how tables, quotes and lists are marked.
+type Transformer struct{}
+
+func (t *Transformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	src := reader.Source()
+
+	var blocks []ast.Node
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && (n.Kind() == ast.KindParagraph || n.Kind() == ast.KindTextBlock) {
+			blocks = append(blocks, n)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, block := range blocks {
+		// A paragraph of its own marks the block before it.
+		if block.Kind() == ast.KindParagraph {
+			if m := standalone.FindSubmatch(bytes.TrimSpace(block.Lines().Value(src))); m != nil {
+				if prev := block.PreviousSibling(); prev != nil {
+					prev.SetAttributeString("id", []byte(ID(string(m[1]))))
+					block.Parent().RemoveChild(block.Parent(), block)
+					continue
+				}
+			}
+		}
+
+		last, ok := block.LastChild().(*ast.Text)
+		if !ok {
+			continue
+		}
+
+		value := last.Segment.Value(src)
+		loc := trailingID.FindSubmatchIndex(value)
+		if loc == nil {
+			continue
+		}
+		id := ID(string(value[loc[2]:loc[3]]))
+
+		last.Segment = last.Segment.WithStop(last.Segment.Start + loc[0])
+		if last.Segment.Len() == 0 {
+			block.RemoveChild(block, last)
+		}
+
+		// Tight list items render their text without a paragraph, so the id
+		// goes on the item itself.
+		target := ast.Node(block)
+		if block.Kind() == ast.KindTextBlock && block.Parent() != nil && block.Parent().Kind() == ast.KindListItem {
+			target = block.Parent()
+		}
+		target.SetAttributeString("id", []byte(id))
+	}
+}
@@ -0,0 +1,124 @@
+package render
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"geode/internal/cache"
+	"geode/internal/config"
+)
+
+const cachedMarker = "<p>from cache</p>"
+
+// renderCached renders files through a cache in dir, then replaces the HTML
+// of every cached entry with cachedMarker, so that the next render shows
+// which notes came from the cache.
+func renderCached(t *testing.T, cfg *config.Config, files map[string]string) map[string]string {
+	t.Helper()
+
+	c, err := cache.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := writeVault(t, files)
+	html := make(map[string]string)
+	for _, page := range NewPipeline(entries, nil, false, nil, 2, c).Render(entries) {
+		html[filepath.ToSlash(page.RelativePath)] = page.HTML
+	}
+	if err := c.Prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = filepath.WalkDir(cfg.Build.Cache, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var e cache.Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return err
+		}
+		e.HTML = cachedMarker
+		if data, err = json.Marshal(e); err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return html
+}
+
+func TestCacheDependencies(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "themes", "test"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	cfg := &config.Config{Theme: "test"}
+	cfg.Build.Cache = filepath.Join(dir, "cache")
+
+	files := map[string]string{
+		"a.md": "Link to [[b]].\n",
+		"b.md": "# B\n\n## Part\n",
+		"c.md": "See [[b#Part]] and [the part](b.md#part).\n",
+		"d.md": "Alone.\n",
+	}
+
+	steps := []struct {
+		name   string
+		change map[string]string
+		cached []string
+		fresh  []string
+	}{
+		{
+			name:   "unrelated note added and edited",
+			change: map[string]string{"e.md": "New.\n", "d.md": "Edited.\n"},
+			cached: []string{"a.md", "b.md", "c.md"},
+			fresh:  []string{"d.md", "e.md"},
+		},
+		{
+			name:   "link target becomes ambiguous",
+			change: map[string]string{"sub/b.md": "Another B.\n"},
+			cached: []string{"b.md", "d.md", "e.md"},
+			fresh:  []string{"a.md", "c.md", "sub/b.md"},
+		},
+		{
+			name:   "heading renamed",
+			change: map[string]string{"b.md": "# B\n\n## Section\n"},
+			cached: []string{"d.md", "e.md", "sub/b.md"},
+			fresh:  []string{"a.md", "b.md", "c.md"},
+		},
+	}
+
+	renderCached(t, cfg, files)
+	for _, step := range steps {
+		for rel, data := range step.change {
+			files[rel] = data
+		}
+
+		html := renderCached(t, cfg, files)
+		for _, rel := range step.cached {
+			if html[rel] != cachedMarker {
+				t.Errorf("%s: %s was rendered again", step.name, rel)
+			}
+		}
+		for _, rel := range step.fresh {
+			if html[rel] == cachedMarker {
+				t.Errorf("%s: %s came from the cache", step.name, rel)
+			}
+		}
+	}
+}
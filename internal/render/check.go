@@ -0,0 +1,412 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"geode/internal/content"
+	"geode/internal/output"
+	"geode/internal/render/externallink"
+	"geode/internal/render/headingid"
+	hashtag "geode/internal/render/tags"
+	"geode/internal/render/wikilink"
+	"geode/internal/types"
+	"geode/internal/utils"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Problem is a broken link, embed or permalink found by Check.
+type Problem struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.Path, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// checkParser only parses links and hashtags, so that link destinations are
+// checked as written rather than as rendered.
+var checkParser = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, extension.Footnote),
+	goldmark.WithParserOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(&wikilink.Parser{}, 199),
+			util.Prioritized(&hashtag.Parser{}, 999),
+		),
+	),
+).Parser()
+
+// Check validates the notes of entries without rendering them: every
+// wikilink, embed, Markdown link and image must point at a published file,
+// every heading or block fragment at an existing heading or block, and no two
+// notes may share a URL. Links to unpublished notes are only reported when
+// unpublishedErrors is set.
+func (p *Pipeline) Check(entries []content.FileEntry, unpublishedErrors bool) []Problem {
+	notes := make([]content.FileEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsMarkdown {
+			notes = append(notes, entry)
+		}
+	}
+
+	found := make([][]Problem, len(notes))
+	utils.Parallel(len(notes), p.jobs, func(_, i int) {
+		found[i] = p.checkNote(notes[i], unpublishedErrors)
+	})
+
+	var problems []Problem
+	for _, f := range found {
+		problems = append(problems, f...)
+	}
+	problems = append(problems, p.checkPermalinks(notes)...)
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Path != problems[j].Path {
+			return problems[i].Path < problems[j].Path
+		}
+		return problems[i].Line < problems[j].Line
+	})
+
+	return problems
+}
+
+func (p *Pipeline) checkNote(entry content.FileEntry, unpublishedErrors bool) []Problem {
+	var problems []Problem
+
+	err := walkNote(entry, func(n ast.Node, _ []byte, line func() int) {
+		from := filepath.ToSlash(entry.RelativePath)
+
+		var msg string
+		switch n := n.(type) {
+		case *wikilink.Node:
+			msg = p.checkWikilink(from, n, unpublishedErrors)
+		case *ast.Link:
+			msg = p.checkDestination(from, string(n.Destination), "link", unpublishedErrors)
+		case *ast.Image:
+			msg = p.checkDestination(from, string(n.Destination), "image", unpublishedErrors)
+		}
+		if msg != "" {
+			problems = append(problems, Problem{Path: entry.Path, Line: line(), Message: msg})
+		}
+	})
+	if err != nil {
+		return []Problem{{Path: entry.Path, Message: err.Error()}}
+	}
+
+	return problems
+}
+
+// ExternalLink is a link or image of a note pointing at another site.
+type ExternalLink struct {
+	Path string
+	Line int
+	URL  string
+}
+
+// ExternalLinks lists the links and images of the notes of entries that point
+// at other sites, in entry order.
+func (p *Pipeline) ExternalLinks(entries []content.FileEntry) []ExternalLink {
+	var links []ExternalLink
+
+	for _, entry := range entries {
+		if !entry.IsMarkdown {
+			continue
+		}
+
+		_ = walkNote(entry, func(n ast.Node, source []byte, line func() int) {
+			var dest string
+			switch n := n.(type) {
+			case *ast.Link:
+				dest = string(n.Destination)
+			case *ast.Image:
+				dest = string(n.Destination)
+			case *ast.AutoLink:
+				if n.AutoLinkType == ast.AutoLinkURL {
+					dest = string(n.URL(source))
+				}
+			}
+			if !externallink.IsExternal(dest) {
+				return
+			}
+
+			if strings.HasPrefix(dest, "//") {
+				dest = "https:" + dest
+			}
+			links = append(links, ExternalLink{Path: entry.Path, Line: line(), URL: dest})
+		})
+	}
+
+	return links
+}
+
+// Outline returns the pages a build would write for the notes of entries,
+// without rendering them: enough to work out every path the build writes.
+func (p *Pipeline) Outline(entries []content.FileEntry) []types.MetaMarkdown {
+	var pages []types.MetaMarkdown
+
+	for _, entry := range entries {
+		if !entry.IsMarkdown {
+			continue
+		}
+
+		src, err := os.ReadFile(entry.Path)
+		if err != nil {
+			continue
+		}
+		frontmatter, body := extractFrontmatter(src)
+		_, embeds, _ := expandMarkdownEmbeds(body, p.embed, entry)
+
+		var contentTags []string
+		_ = walkNote(entry, func(n ast.Node, _ []byte, _ func() int) {
+			if tag, ok := n.(*hashtag.Node); ok {
+				contentTags = append(contentTags, string(tag.Tag))
+			}
+		})
+		slices.Sort(contentTags)
+
+		pages = append(pages, types.MetaMarkdown{
+			Path:         entry.Path,
+			RelativePath: entry.RelativePath,
+			Link:         ExtractPermalink(frontmatter, entry),
+			Title:        ExtractTitle(frontmatter, entry),
+			Frontmatter:  frontmatter,
+			Tags:         mergeTags(parseFrontmatterTags(frontmatter), slices.Compact(contentTags)),
+			Embeds:       embeds,
+			Aliases:      ExtractAliases(frontmatter),
+			RedirectFrom: frontmatterStrings(frontmatter, "redirect_from"),
+		})
+	}
+
+	return pages
+}
+
+// walkNote parses the note of entry and calls visit with every node, the
+// parsed source, and a function returning the line of the note the node
+// starts on.
+func walkNote(entry content.FileEntry, visit func(n ast.Node, source []byte, line func() int)) error {
+	src, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return err
+	}
+
+	_, body := extractFrontmatter(src)
+	base := bodyOffset(src, body)
+
+	doc := checkParser.Parse(text.NewReader(body))
+	return ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			visit(n, body, func() int { return lineAt(src, base+nodeOffset(n, body)) })
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func (p *Pipeline) checkWikilink(from string, n *wikilink.Node, unpublishedErrors bool) string {
+	literal := "[[" + string(n.Target)
+	if len(n.Fragment) > 0 {
+		literal += "#" + string(n.Fragment)
+	}
+	literal += "]]"
+
+	kind := "link"
+	if n.Embed {
+		kind, literal = "embed", "!"+literal
+	}
+
+	target := strings.TrimSpace(string(n.Target))
+	if target == "" {
+		return p.checkFragment(from, string(n.Fragment), literal)
+	}
+
+	index := p.index
+	if n.Embed {
+		index = p.embed.index
+	}
+	m := index.Resolve(from, target)
+	if m.Path == "" {
+		if p.unpublished.Resolve(from, target).Path != "" {
+			if unpublishedErrors {
+				return fmt.Sprintf("%s to unpublished note %s", kind, literal)
+			}
+			return ""
+		}
+		return fmt.Sprintf("unresolved %s %s", kind, literal)
+	}
+
+	return p.checkFragment(m.Path, string(n.Fragment), literal)
+}
+
+func (p *Pipeline) checkDestination(from, dest, kind string, unpublishedErrors bool) string {
+	literal := fmt.Sprintf("%q", dest)
+
+	if anchor, ok := strings.CutPrefix(dest, "#"); ok {
+		if unescaped, err := url.PathUnescape(anchor); err == nil {
+			anchor = unescaped
+		}
+		return p.checkFragment(from, anchor, literal)
+	}
+
+	target, fragment, ok := wikilink.SplitDestination(dest)
+	if !ok {
+		return ""
+	}
+
+	m := p.index.ResolvePath(from, target)
+	if m.Path == "" {
+		if p.unpublished.ResolvePath(from, target).Path != "" {
+			if unpublishedErrors {
+				return fmt.Sprintf("%s to unpublished note %s", kind, literal)
+			}
+			return ""
+		}
+		// Paths without an extension may be pages of the site itself.
+		if kind == "image" || path.Ext(target) != "" {
+			return fmt.Sprintf("unresolved %s %s", kind, literal)
+		}
+		return ""
+	}
+
+	return p.checkFragment(m.Path, fragment, literal)
+}
+
+// checkFragment checks that the note file has the heading or block fragment
+// refers to. Fragments of other files are not checked.
+func (p *Pipeline) checkFragment(file, fragment, literal string) string {
+	fragment = strings.TrimSpace(fragment)
+	notePath, ok := p.embed.paths[file]
+	if fragment == "" || !ok {
+		return ""
+	}
+
+	if strings.HasPrefix(fragment, "^") {
+		if _, _, ok := readEmbed(notePath, fragment); !ok {
+			return fmt.Sprintf("%s: no block %s in %s", literal, fragment, file)
+		}
+		return ""
+	}
+
+	if _, ok := headingid.Match(p.headings[file], fragment); !ok {
+		return fmt.Sprintf("%s: no heading %q in %s", literal, fragment, file)
+	}
+	return ""
+}
+
+// checkPermalinks reports every note whose URL is shared with other notes or
+// leads out of the output directory.
+func (p *Pipeline) checkPermalinks(notes []content.FileEntry) []Problem {
+	owners := make(map[string][]string) // URL -> notes
+	for _, entry := range notes {
+		file := filepath.ToSlash(entry.RelativePath)
+		if link := p.urls[file]; link != "" {
+			owners[link] = append(owners[link], file)
+		}
+	}
+
+	var problems []Problem
+	for _, entry := range notes {
+		file := filepath.ToSlash(entry.RelativePath)
+		link := p.urls[file]
+		if err := output.CheckPath(output.PagePath(link)); err != nil {
+			problems = append(problems, Problem{
+				Path:    entry.Path,
+				Line:    frontmatterLine(entry.Path, "permalink"),
+				Message: fmt.Sprintf("permalink %s: %v", link, err),
+			})
+			continue
+		}
+		if len(owners[link]) < 2 {
+			continue
+		}
+
+		others := slices.DeleteFunc(slices.Clone(owners[link]), func(f string) bool { return f == file })
+		problems = append(problems, Problem{
+			Path:    entry.Path,
+			Line:    frontmatterLine(entry.Path, "permalink"),
+			Message: fmt.Sprintf("permalink %s is also used by %s", link, strings.Join(others, ", ")),
+		})
+	}
+
+	return problems
+}
+
+// frontmatterLine returns the line the frontmatter of the note at path sets
+// key on, or 0.
+func frontmatterLine(path, key string) int {
+	src, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(src, []byte("---")) {
+		return 0
+	}
+
+	lines := strings.Split(string(src), "\n")
+	for i, line := range lines[1:] {
+		if strings.TrimSpace(line) == "---" {
+			break
+		}
+		if strings.HasPrefix(line, key+":") {
+			return i + 2
+		}
+	}
+	return 0
+}
+
+// bodyOffset returns where body, as returned by extractFrontmatter, starts in
+// src.
+func bodyOffset(src, body []byte) int {
+	if len(body) == len(src) {
+		return 0
+	}
+	return max(len(bytes.TrimRightFunc(src, unicode.IsSpace))-len(body), 0)
+}
+
+func lineAt(src []byte, offset int) int {
+	offset = min(max(offset, 0), len(src))
+	return bytes.Count(src[:offset], []byte{'\n'}) + 1
+}
+
+// nodeOffset returns where n starts in source: at its first text, or at the
+// first line of the block it is in.
+func nodeOffset(n ast.Node, source []byte) int {
+	offset := -1
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := c.(*ast.Text); ok && entering {
+			offset = t.Segment.Start
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	if offset >= 0 {
+		return offset
+	}
+
+	for b := n; b != nil; b = b.Parent() {
+		if b.Type() == ast.TypeBlock && b.Lines().Len() > 0 {
+			start := b.Lines().At(0).Start
+			// Autolinks keep no position, find their URL in the block.
+			if link, ok := n.(*ast.AutoLink); ok {
+				if i := bytes.Index(source[start:], link.URL(source)); i >= 0 {
+					return start + i
+				}
+			}
+			return start
+		}
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package codeembed
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"geode/internal/render/highlight"
+	"html"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Range is a 1-based, inclusive range of lines. A zero End runs to the end of
+// the file.
+type Range struct {
+	Start, End int
+}
+
+// ParseRange reads a line range fragment such as L10, L10-L40 or L10-40. An
+// empty fragment selects the whole file.
+func ParseRange(fragment string) (Range, error) {
+	fragment = strings.TrimSpace(fragment)
+	if fragment == "" {
+		return Range{Start: 1}, nil
+	}
+
+	from, to, isRange := strings.Cut(fragment, "-")
+	start, ok := parseLine(from)
+	if !ok {
+		return Range{}, fmt.Errorf("invalid line range %q", fragment)
+	}
+	if !isRange {
+		return Range{Start: start, End: start}, nil
+	}
+
+	end, ok := parseLine(to)
+	if !ok || end < start {
+		return Range{}, fmt.Errorf("invalid line range %q", fragment)
+	}
+	return Range{Start: start, End: end}, nil
+}
+
+func parseLine(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) > 0 && (s[0] == 'L' || s[0] == 'l') {
+		s = s[1:]
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n > 0
+}
+
+// Render writes the lines of data in rng as a highlighted code block, headed
+// by a link to the file at href.
+func Render(data []byte, name, href string, rng Range) ([]byte, error) {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, errors.New("not a text file")
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if rng.Start > len(lines) {
+		return nil, fmt.Errorf("line %d is past the end of the file (%d lines)", rng.Start, len(lines))
+	}
+	end := rng.End
+	if end == 0 || end > len(lines) {
+		end = len(lines)
+	}
+	code := strings.Join(lines[rng.Start-1:end], "")
+
+	var buf bytes.Buffer
+	buf.WriteString(`<div class="code-embed"><div class="code-embed-header"><a href="`)
+	buf.WriteString(html.EscapeString(href))
+	buf.WriteString(`">`)
+	buf.WriteString(html.EscapeString(name))
+	buf.WriteString(`</a>`)
+	switch {
+	case rng.Start == end && len(lines) > 1:
+		fmt.Fprintf(&buf, `<span class="code-embed-lines">line %d</span>`, end)
+	case rng.Start > 1 || end < len(lines):
+		fmt.Fprintf(&buf, `<span class="code-embed-lines">lines %d–%d</span>`, rng.Start, end)
+	}
+	buf.WriteString(`</div>`)
+
+	lang := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if err := highlight.Highlight(&buf, code, lang); err != nil {
+		return nil, err
+	}
+	buf.WriteString("</div>\n")
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,79 @@
+package codeembed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		fragment string
+		want     Range
+		wantErr  bool
+	}{
+		{"", Range{Start: 1}, false},
+		{"  ", Range{Start: 1}, false},
+		{"L10", Range{Start: 10, End: 10}, false},
+		{"l10", Range{Start: 10, End: 10}, false},
+		{"10", Range{Start: 10, End: 10}, false},
+		{"L10-L40", Range{Start: 10, End: 40}, false},
+		{"L10-40", Range{Start: 10, End: 40}, false},
+		{" L10 - L40 ", Range{Start: 10, End: 40}, false},
+		{"L5-L5", Range{Start: 5, End: 5}, false},
+		{"L40-L10", Range{}, true},
+		{"L0", Range{}, true},
+		{"L-3", Range{}, true},
+		{"L10-", Range{}, true},
+		{"Heading", Range{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRange(tt.fragment)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRange(%q) = %+v, %v, want %+v", tt.fragment, got, err, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := "package main\r\n\r\nfunc main() {\r\n\tprintln(\"<hi>\")\r\n}\r\n"
+
+	tests := []struct {
+		rng     Range
+		want    []string
+		notWant []string
+	}{
+		{Range{Start: 1}, []string{`<a href="/src/main.go">main.go</a></div>`, "main"}, []string{"code-embed-lines"}},
+		{Range{Start: 3, End: 5}, []string{"lines 3–5", "&lt;hi&gt;"}, []string{"package"}},
+		{Range{Start: 4, End: 4}, []string{"line 4"}, []string{"func"}},
+		{Range{Start: 2, End: 99}, []string{"lines 2–5"}, []string{"package"}},
+	}
+
+	for _, tt := range tests {
+		out, err := Render([]byte(data), "main.go", "/src/main.go", tt.rng)
+		if err != nil {
+			t.Fatalf("Render(%+v): %v", tt.rng, err)
+		}
+		html := string(out)
+		for _, want := range tt.want {
+			if !strings.Contains(html, want) {
+				t.Errorf("Render(%+v) lacks %s:\n%s", tt.rng, want, html)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(html, notWant) {
+				t.Errorf("Render(%+v) has %s:\n%s", tt.rng, notWant, html)
+			}
+		}
+	}
+
+	if _, err := Render([]byte(data), "main.go", "/src/main.go", Range{Start: 6, End: 6}); err == nil {
+		t.Error("range past the end: no error")
+	}
+	if _, err := Render([]byte("\x00\x01"), "blob.txt", "/blob.txt", Range{Start: 1}); err == nil {
+		t.Error("binary file: no error")
+	}
+	if _, err := Render(nil, "empty.txt", "/empty.txt", Range{Start: 1}); err != nil {
+		t.Errorf("empty file: %v", err)
+	}
+}
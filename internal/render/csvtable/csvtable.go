@@ -0,0 +1,238 @@
+package csvtable
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Header tells whether the first row of a file holds column names.
+type Header int
+
+const (
+	HeaderAuto Header = iota
+	HeaderYes
+	HeaderNo
+)
+
+// Options select what part of a CSV file is shown. They are read from the
+// label of an embed, as in ![[data.csv|rows=20|columns=Name,Price|header=no]].
+type Options struct {
+	Header  Header
+	Rows    int      // data rows shown, all if zero
+	Columns []string // column names or 1-based indexes, all if empty
+}
+
+// ParseOptions reads the key=value parts of an embed label. Parts it does not
+// know are ignored.
+func ParseOptions(label string) Options {
+	var o Options
+
+	for _, part := range strings.Split(label, "|") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "header":
+			switch strings.ToLower(value) {
+			case "yes", "true", "on":
+				o.Header = HeaderYes
+			case "no", "false", "off":
+				o.Header = HeaderNo
+			}
+		case "rows":
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				o.Rows = n
+			}
+		case "columns", "cols":
+			for _, col := range strings.Split(value, ",") {
+				if col = strings.TrimSpace(col); col != "" {
+					o.Columns = append(o.Columns, col)
+				}
+			}
+		}
+	}
+
+	return o
+}
+
+// Render writes data as an HTML table followed by a link to the file at href.
+func Render(data []byte, name, href string, opts Options) ([]byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse csv: %w", err)
+	}
+
+	var head []string
+	hasHeader := opts.Header == HeaderYes || (opts.Header == HeaderAuto && detectHeader(records))
+	if hasHeader && len(records) > 0 {
+		head, records = records[0], records[1:]
+	}
+
+	cols, err := selectColumns(head, width(head, records), opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(records)
+	if opts.Rows > 0 && opts.Rows < total {
+		records = records[:opts.Rows]
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<div class="csv-embed"><table>`)
+
+	if head != nil {
+		buf.WriteString(`<thead><tr>`)
+		for _, i := range cols {
+			buf.WriteString(`<th>`)
+			writeCell(&buf, head, i)
+			buf.WriteString(`</th>`)
+		}
+		buf.WriteString(`</tr></thead>`)
+	}
+
+	buf.WriteString(`<tbody>`)
+	for _, record := range records {
+		buf.WriteString(`<tr>`)
+		for _, i := range cols {
+			buf.WriteString(`<td>`)
+			writeCell(&buf, record, i)
+			buf.WriteString(`</td>`)
+		}
+		buf.WriteString(`</tr>`)
+	}
+	buf.WriteString(`</tbody></table><div class="csv-embed-footer">`)
+
+	if len(records) < total {
+		fmt.Fprintf(&buf, "Showing %d of %d rows · ", len(records), total)
+	}
+	buf.WriteString(`<a href="`)
+	buf.WriteString(html.EscapeString(href))
+	buf.WriteString(`" download>`)
+	buf.WriteString(html.EscapeString(name))
+	buf.WriteString("</a></div></div>\n")
+
+	return buf.Bytes(), nil
+}
+
+// detectHeader guesses that the first row names the columns when all of its
+// cells are distinct, non-empty and not numbers, and the rows below hold
+// numbers or longer values.
+func detectHeader(records [][]string) bool {
+	if len(records) < 2 {
+		return false
+	}
+
+	first := records[0]
+	seen := make(map[string]struct{}, len(first))
+	for _, cell := range first {
+		cell = strings.TrimSpace(cell)
+		if cell == "" || isNumber(cell) {
+			return false
+		}
+		if _, ok := seen[cell]; ok {
+			return false
+		}
+		seen[cell] = struct{}{}
+	}
+
+	for _, record := range records[1:] {
+		for _, cell := range record {
+			if isNumber(strings.TrimSpace(cell)) {
+				return true
+			}
+		}
+	}
+
+	// Without numbers to go by, a first row that looks like the others is
+	// more likely data. Names are usually shorter than values.
+	return avgLen(first) < avgLen(records[1])
+}
+
+func isNumber(s string) bool {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, ",", ""), "%")
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func avgLen(record []string) float64 {
+	if len(record) == 0 {
+		return 0
+	}
+	n := 0
+	for _, cell := range record {
+		n += len(strings.TrimSpace(cell))
+	}
+	return float64(n) / float64(len(record))
+}
+
+func width(head []string, records [][]string) int {
+	n := len(head)
+	for _, record := range records {
+		n = max(n, len(record))
+	}
+	return n
+}
+
+// selectColumns returns the indexes of the columns to show, picked by name
+// or by 1-based index.
+func selectColumns(head []string, n int, want []string) ([]int, error) {
+	if len(want) == 0 {
+		cols := make([]int, n)
+		for i := range cols {
+			cols[i] = i
+		}
+		return cols, nil
+	}
+
+	cols := make([]int, 0, len(want))
+	for _, col := range want {
+		i := columnIndex(head, col)
+		if i < 0 {
+			if j, err := strconv.Atoi(col); err == nil && j >= 1 && j <= n {
+				i = j - 1
+			}
+		}
+		if i < 0 {
+			return nil, fmt.Errorf("no column %q", col)
+		}
+		cols = append(cols, i)
+	}
+	return cols, nil
+}
+
+func columnIndex(head []string, name string) int {
+	for i, cell := range head {
+		if strings.EqualFold(strings.TrimSpace(cell), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeCell writes a cell of record, keeping the line breaks of quoted
+// multiline fields. Short rows have empty cells.
+func writeCell(buf *bytes.Buffer, record []string, i int) {
+	if i >= len(record) {
+		return
+	}
+
+	value := strings.ReplaceAll(record[i], "\r\n", "\n")
+	for j, line := range strings.Split(value, "\n") {
+		if j > 0 {
+			buf.WriteString("<br>")
+		}
+		buf.WriteString(html.EscapeString(line))
+	}
+}
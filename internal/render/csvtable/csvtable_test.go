@@ -0,0 +1,160 @@
+package csvtable
+
+import (
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		label string
+		want  Options
+	}{
+		{"", Options{}},
+		{"A caption", Options{}},
+		{"header=yes", Options{Header: HeaderYes}},
+		{"header = No", Options{Header: HeaderNo}},
+		{"header=maybe", Options{}},
+		{"rows=20", Options{Rows: 20}},
+		{"rows=0", Options{}},
+		{"rows=-3", Options{}},
+		{"rows=many", Options{}},
+		{"columns=Name, Price,,3", Options{Columns: []string{"Name", "Price", "3"}}},
+		{"cols=1", Options{Columns: []string{"1"}}},
+		{"rows=5|header=off|columns=Name", Options{Header: HeaderNo, Rows: 5, Columns: []string{"Name"}}},
+		{"ROWS=2|unknown=1", Options{Rows: 2}},
+	}
+
+	for _, tt := range tests {
+		got := ParseOptions(tt.label)
+		if got.Header != tt.want.Header || got.Rows != tt.want.Rows || !slices.Equal(got.Columns, tt.want.Columns) {
+			t.Errorf("ParseOptions(%q) = %+v, want %+v", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestDetectHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want bool
+	}{
+		{"numbers below names", "Name,Price\nApple,1.20\nPear,0.90", true},
+		{"percentages and thousands", "Region,Share\nNorth,12%\nSouth,\"1,024\"", true},
+		{"numbers in the first row", "2023,2024\n1,2", false},
+		{"empty cell", "Name,\nApple,1", false},
+		{"duplicate names", "Name,Name\nApple,1", false},
+		{"single row", "Name,Price", false},
+		{"short names over long values", "Name,City\nAlexandra,Amsterdam\nBartholomew,Copenhagen", true},
+		{"longer first row", "Bartholomew,Copenhagen\nAnn,Oslo", false},
+	}
+
+	for _, tt := range tests {
+		if got := detectHeader(parse(t, tt.csv)); got != tt.want {
+			t.Errorf("%s: detectHeader() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	head := []string{"Name", " Price ", "3"}
+
+	tests := []struct {
+		want    []string
+		cols    []int
+		wantErr bool
+	}{
+		{nil, []int{0, 1, 2, 3}, false},
+		{[]string{"price", "name"}, []int{1, 0}, false},
+		{[]string{"4"}, []int{3}, false},
+		{[]string{"3"}, []int{2}, false},
+		{[]string{"5"}, nil, true},
+		{[]string{"0"}, nil, true},
+		{[]string{"Nope"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		cols, err := selectColumns(head, 4, tt.want)
+		if (err != nil) != tt.wantErr || !slices.Equal(cols, tt.cols) {
+			t.Errorf("selectColumns(%q) = %v, %v, want %v", tt.want, cols, err, tt.cols)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := "\xef\xbb\xbfName,Price,Note\nApple,1.20,\"red\ngreen\"\nPear,0.90\nPlum,2.00,<ripe>\n"
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "detected header",
+			want: []string{
+				"<thead><tr><th>Name</th><th>Price</th><th>Note</th></tr></thead>",
+				"<td>red<br>green</td>",
+				"<tr><td>Pear</td><td>0.90</td><td></td></tr>",
+				"<td>&lt;ripe&gt;</td>",
+				`<a href="/data.csv" download>data.csv</a>`,
+			},
+			notWant: []string{"Showing"},
+		},
+		{
+			name:    "no header",
+			opts:    Options{Header: HeaderNo},
+			want:    []string{"<tr><td>Name</td><td>Price</td><td>Note</td></tr>"},
+			notWant: []string{"<thead>"},
+		},
+		{
+			name: "rows and columns",
+			opts: Options{Rows: 2, Columns: []string{"price", "1"}},
+			want: []string{
+				"<thead><tr><th>Price</th><th>Name</th></tr></thead>",
+				"<tr><td>1.20</td><td>Apple</td></tr><tr><td>0.90</td><td>Pear</td></tr></tbody>",
+				"Showing 2 of 3 rows",
+			},
+			notWant: []string{"Plum"},
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := Render([]byte(data), "data.csv", "/data.csv", tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		html := string(out)
+		for _, want := range tt.want {
+			if !strings.Contains(html, want) {
+				t.Errorf("%s: output lacks %s:\n%s", tt.name, want, html)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(html, notWant) {
+				t.Errorf("%s: output has %s:\n%s", tt.name, notWant, html)
+			}
+		}
+	}
+
+	if _, err := Render([]byte("a,\"b\nc"), "bad.csv", "/bad.csv", Options{}); err == nil {
+		t.Error("unterminated quote: no error")
+	}
+	if _, err := Render([]byte(data), "data.csv", "/data.csv", Options{Columns: []string{"Nope"}}); err == nil {
+		t.Error("unknown column: no error")
+	}
+}
+
+func parse(t *testing.T, data string) [][]string {
+	t.Helper()
+
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
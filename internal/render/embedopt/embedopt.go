@@ -0,0 +1,108 @@
+package embedopt
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// Options are the display options of an embedded image or player, read from
+// the label of the embed: the text after the pipe of ![[file|...]], or the
+// alt text of ![...](file).
+type Options struct {
+	Alt     string
+	Caption string
+	Width   int
+	Height  int
+}
+
+// Parse reads a label of the form "alt|caption|size", where every part is
+// optional. The size is the last part and is either a width ("300") or a
+// width and a height ("300x200"). A label holding only a size has no alt.
+func Parse(label string) Options {
+	var o Options
+
+	parts := strings.Split(label, "|")
+	if w, h, ok := parseSize(parts[len(parts)-1]); ok {
+		o.Width, o.Height = w, h
+		parts = parts[:len(parts)-1]
+	}
+
+	if len(parts) > 0 {
+		o.Alt = strings.TrimSpace(parts[0])
+	}
+	if len(parts) > 1 {
+		o.Caption = strings.TrimSpace(strings.Join(parts[1:], "|"))
+	}
+
+	return o
+}
+
+func parseSize(s string) (width, height int, ok bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "×", "x")
+	if s == "" {
+		return 0, 0, false
+	}
+
+	ws, hs, hasHeight := strings.Cut(s, "x")
+	width, err := strconv.Atoi(ws)
+	if err != nil || width <= 0 {
+		return 0, 0, false
+	}
+	if !hasHeight {
+		return width, 0, true
+	}
+
+	height, err = strconv.Atoi(hs)
+	if err != nil || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+// WriteSize writes the width and height attributes, each only when set.
+func (o Options) WriteSize(w util.BufWriter) {
+	if o.Width > 0 {
+		_, _ = w.WriteString(` width="`)
+		_, _ = w.WriteString(strconv.Itoa(o.Width))
+		_, _ = w.WriteString(`"`)
+	}
+	if o.Height > 0 {
+		_, _ = w.WriteString(` height="`)
+		_, _ = w.WriteString(strconv.Itoa(o.Height))
+		_, _ = w.WriteString(`"`)
+	}
+}
+
+// WriteAlt writes the alt attribute, falling back to fallback when the label
+// sets no alt text.
+func (o Options) WriteAlt(w util.BufWriter, fallback string) {
+	alt := o.Alt
+	if alt == "" {
+		alt = fallback
+	}
+
+	_, _ = w.WriteString(` alt="`)
+	_, _ = w.Write(util.EscapeHTML([]byte(alt)))
+	_, _ = w.WriteString(`"`)
+}
+
+// OpenFigure starts the element wrapping an embed with a caption. Embeds sit
+// inside paragraphs, so it is made of spans rather than a figure.
+func (o Options) OpenFigure(w util.BufWriter) {
+	if o.Caption != "" {
+		_, _ = w.WriteString(`<span class="embed-figure">`)
+	}
+}
+
+// CloseFigure writes the caption and closes the element OpenFigure started.
+func (o Options) CloseFigure(w util.BufWriter) {
+	if o.Caption == "" {
+		return
+	}
+
+	_, _ = w.WriteString(`<span class="embed-caption">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(o.Caption)))
+	_, _ = w.WriteString(`</span></span>`)
+}
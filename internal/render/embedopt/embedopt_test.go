@@ -0,0 +1,32 @@
+package embedopt
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		label string
+		want  Options
+	}{
+		{"", Options{}},
+		{"A cat", Options{Alt: "A cat"}},
+		{"300", Options{Width: 300}},
+		{"300x200", Options{Width: 300, Height: 200}},
+		{"300×200", Options{Width: 300, Height: 200}},
+		{" 300 x 200 ", Options{Alt: "300 x 200"}},
+		{"A cat|300", Options{Alt: "A cat", Width: 300}},
+		{"A cat|Sleeping on the sofa", Options{Alt: "A cat", Caption: "Sleeping on the sofa"}},
+		{"A cat|Sleeping|300x200", Options{Alt: "A cat", Caption: "Sleeping", Width: 300, Height: 200}},
+		{"A cat|Sleeping|on the sofa", Options{Alt: "A cat", Caption: "Sleeping|on the sofa"}},
+		{"|Caption only|", Options{Caption: "Caption only|"}},
+		{"|300", Options{Width: 300}},
+		{"A cat|0", Options{Alt: "A cat", Caption: "0"}},
+		{"A cat|300x", Options{Alt: "A cat", Caption: "300x"}},
+		{"A cat|-300", Options{Alt: "A cat", Caption: "-300"}},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.label); got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.label, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package render
 
 import (
 	"geode/internal/types"
+	"sort"
 	"strings"
 )
 
@@ -13,6 +14,9 @@ func BuildFileTree(pages []types.MetaMarkdown) *types.FileTree {
 		insertIntoTree(root, segments, p)
 	}
 
+	// Sorted once here so the tree can be rendered concurrently.
+	sortTree(root)
+
 	return root
 }
 
@@ -48,3 +52,27 @@ func insertIntoTree(node *types.FileTree, segments []string, page types.MetaMark
 
 	insertIntoTree(child, segments[1:], page)
 }
+
+func sortTree(node *types.FileTree) {
+	if len(node.Children) == 0 {
+		return
+	}
+
+	sort.Slice(node.Children, func(i, j int) bool {
+		a := node.Children[i]
+		b := node.Children[j]
+
+		aIsFile := a.Link != "" || a.Path != ""
+		bIsFile := b.Link != "" || b.Path != ""
+
+		if aIsFile != bIsFile {
+			return !aIsFile
+		}
+
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+
+	for _, child := range node.Children {
+		sortTree(child)
+	}
+}
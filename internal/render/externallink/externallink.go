@@ -40,7 +40,7 @@ func (t *Transformer) Transform(node *ast.Document, reader text.Reader, pc parse
 			link := n.(*ast.Link)
 			dest := string(link.Destination)
 
-			if isExternalLink(dest) {
+			if IsExternal(dest) {
 				link.SetAttributeString("data-external", true)
 			}
 		}
@@ -49,7 +49,8 @@ func (t *Transformer) Transform(node *ast.Document, reader text.Reader, pc parse
 	})
 }
 
-func isExternalLink(dest string) bool {
+// IsExternal reports whether dest points at another site.
+func IsExternal(dest string) bool {
 	if dest == "" {
 		return false
 	}
@@ -0,0 +1,204 @@
+package headingid
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// IDs generates the ids of the headings of one document. It is plugged into
+// goldmark's parser context, so the rendered headings, the table of contents
+// and the anchors links point at all agree.
+type IDs struct {
+	used map[string]struct{}
+}
+
+var _ parser.IDs = (*IDs)(nil)
+
+func New() *IDs {
+	return &IDs{used: make(map[string]struct{})}
+}
+
+// Generate returns the slug of value, suffixed with -1, -2, ... when the
+// document already has an element with that id.
+func (ids *IDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	id := Slug(string(value))
+	if id == "" {
+		id = "id"
+		if kind == ast.KindHeading {
+			id = "heading"
+		}
+	}
+
+	unique := id
+	for i := 1; ids.taken(unique); i++ {
+		unique = fmt.Sprintf("%s-%d", id, i)
+	}
+	ids.Put([]byte(unique))
+
+	return []byte(unique)
+}
+
+func (ids *IDs) Put(value []byte) {
+	ids.used[string(value)] = struct{}{}
+}
+
+func (ids *IDs) taken(id string) bool {
+	_, ok := ids.used[id]
+	return ok
+}
+
+// Slug lowercases text and keeps letters, marks and digits of any script.
+// Spaces, dashes and underscores become dashes, everything else is dropped.
+func Slug(text string) string {
+	var b strings.Builder
+
+	for _, r := range strings.TrimSpace(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			b.WriteRune('-')
+		}
+	}
+
+	return strings.Trim(b.String(), "-")
+}
+
+// Heading is a heading of a note as it is rendered. Start and End delimit
+// its section in the source: from the heading up to the next heading of the
+// same or a higher level.
+type Heading struct {
+	Level int
+	Text  string
+	ID    string
+	Start int
+	End   int
+}
+
+var headingParser = parser.NewParser(
+	parser.WithBlockParsers(parser.DefaultBlockParsers()...),
+	parser.WithAutoHeadingID(),
+)
+
+// Parse returns the headings of a Markdown document in order, with the ids
+// they get when the document is rendered on its own.
+func Parse(source []byte) []Heading {
+	doc := headingParser.Parse(text.NewReader(source), parser.WithContext(parser.NewContext(parser.WithIDs(New()))))
+
+	var headings []Heading
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		// Empty headings still take an id, but nothing can link to them.
+		lines := h.Lines()
+		if lines.Len() == 0 {
+			return ast.WalkSkipChildren, nil
+		}
+
+		last := lines.At(lines.Len() - 1)
+		heading := Heading{
+			Level: h.Level,
+			Text:  strings.TrimSpace(string(last.Value(source))),
+			Start: lineStart(source, lines.At(0).Start),
+			End:   len(source),
+		}
+		if id, ok := h.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok {
+				heading.ID = string(b)
+			}
+		}
+
+		headings = append(headings, heading)
+		return ast.WalkSkipChildren, nil
+	})
+
+	for i := range headings {
+		for _, next := range headings[i+1:] {
+			if next.Level <= headings[i].Level {
+				headings[i].End = next.Start
+				break
+			}
+		}
+	}
+
+	return headings
+}
+
+func lineStart(source []byte, offset int) int {
+	for offset > 0 && source[offset-1] != '\n' {
+		offset--
+	}
+	return offset
+}
+
+// Match finds the heading an Obsidian link fragment refers to. Like Obsidian,
+// a fragment may name a path of nested headings ("Parent#Child"), and each
+// part matches the heading text regardless of case and of the characters a
+// link cannot hold. A part may also be the id itself.
+func Match(headings []Heading, fragment string) (Heading, bool) {
+	var (
+		match Heading
+		found bool
+	)
+
+	from, until := 0, len(headings)
+	for part := range strings.SplitSeq(fragment, "#") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		i := find(headings[from:until], part)
+		if i < 0 {
+			return Heading{}, false
+		}
+		i += from
+
+		match, found = headings[i], true
+
+		// The next part must be nested under this heading.
+		from, until = i+1, i+1
+		for until < len(headings) && headings[until].Level > match.Level {
+			until++
+		}
+	}
+
+	return match, found
+}
+
+func find(headings []Heading, part string) int {
+	want := normalize(part)
+	for i, h := range headings {
+		if normalize(h.Text) == want {
+			return i
+		}
+	}
+
+	for i, h := range headings {
+		if h.ID == part || Slug(h.Text) == Slug(part) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// normalize folds case, spacing and the characters Obsidian strips from
+// link fragments.
+func normalize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("#^|[]:%\\", r) {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
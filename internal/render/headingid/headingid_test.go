@@ -0,0 +1,153 @@
+package headingid
+
+import (
+	"testing"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Hello World", "hello-world"},
+		{"  Trimmed  ", "trimmed"},
+		{"snake_case and-dash", "snake-case-and-dash"},
+		{"What's new?", "whats-new"},
+		{"C++ & Go: 2024", "c--go-2024"},
+		{"Ünïcödé", "ünïcödé"},
+		{"日本語の見出し", "日本語の見出し"},
+		{"-- Dashes --", "dashes"},
+		{"!!!", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Slug(tt.text); got != tt.want {
+			t.Errorf("Slug(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	ids := New()
+	ids.Put([]byte("taken"))
+
+	tests := []struct {
+		value string
+		kind  ast.NodeKind
+		want  string
+	}{
+		{"Intro", ast.KindHeading, "intro"},
+		{"Intro", ast.KindHeading, "intro-1"},
+		{"intro", ast.KindHeading, "intro-2"},
+		{"Taken", ast.KindHeading, "taken-1"},
+		{"???", ast.KindHeading, "heading"},
+		{"???", ast.KindHeading, "heading-1"},
+		{"", ast.KindParagraph, "id"},
+	}
+
+	for _, tt := range tests {
+		if got := string(ids.Generate([]byte(tt.value), tt.kind)); got != tt.want {
+			t.Errorf("Generate(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+const doc = `# Title
+
+Intro.
+
+## Setup
+
+### Install
+
+Steps.
+
+## Usage
+
+### Install
+
+Again.
+
+## Usage
+`
+
+func TestParse(t *testing.T) {
+	want := []Heading{
+		{Level: 1, Text: "Title", ID: "title"},
+		{Level: 2, Text: "Setup", ID: "setup"},
+		{Level: 3, Text: "Install", ID: "install"},
+		{Level: 2, Text: "Usage", ID: "usage"},
+		{Level: 3, Text: "Install", ID: "install-1"},
+		{Level: 2, Text: "Usage", ID: "usage-1"},
+	}
+
+	got := Parse([]byte(doc))
+	if len(got) != len(want) {
+		t.Fatalf("got %d headings, want %d", len(got), len(want))
+	}
+
+	for i, h := range got {
+		if h.Level != want[i].Level || h.Text != want[i].Text || h.ID != want[i].ID {
+			t.Errorf("heading %d = %+v, want %+v", i, h, want[i])
+		}
+	}
+
+	// A section runs up to the next heading of the same or a higher level.
+	if section := doc[got[1].Start:got[1].End]; section != "## Setup\n\n### Install\n\nSteps.\n\n" {
+		t.Errorf("Setup section = %q", section)
+	}
+	if section := doc[got[0].Start:got[0].End]; section != doc {
+		t.Errorf("Title section = %q, want the whole document", section)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	headings := Parse([]byte(doc))
+
+	tests := []struct {
+		fragment string
+		wantID   string
+		wantOK   bool
+	}{
+		{"Setup", "setup", true},
+		{"setup", "setup", true},
+		{"  Setup  ", "setup", true},
+		{"Install", "install", true},
+		{"Setup#Install", "install", true},
+		{"Usage#Install", "install-1", true},
+		{"Title#Usage#Install", "install-1", true},
+		{"install-1", "install-1", true},
+		{"usage-1", "usage-1", true},
+		{"Install#Setup", "", false},
+		{"Setup#Usage", "", false},
+		{"Missing", "", false},
+		{"", "", false},
+		{"#", "", false},
+	}
+
+	for _, tt := range tests {
+		h, ok := Match(headings, tt.fragment)
+		if ok != tt.wantOK || h.ID != tt.wantID {
+			t.Errorf("Match(%q) = %q, %v, want %q, %v", tt.fragment, h.ID, ok, tt.wantID, tt.wantOK)
+		}
+	}
+}
+
+func TestMatchStrippedCharacters(t *testing.T) {
+	headings := Parse([]byte("# Q&A: What is 100%?\n\n# [[Linked]] heading\n"))
+
+	tests := []struct {
+		fragment, wantID string
+	}{
+		{"Q&A What is 100", "qa-what-is-100"},
+		{"q&a: what is 100%?", "qa-what-is-100"},
+		{"Linked heading", "linked-heading"},
+	}
+
+	for _, tt := range tests {
+		if h, ok := Match(headings, tt.fragment); !ok || h.ID != tt.wantID {
+			t.Errorf("Match(%q) = %q, %v, want %q", tt.fragment, h.ID, ok, tt.wantID)
+		}
+	}
+}
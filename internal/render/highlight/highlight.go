@@ -2,6 +2,7 @@ package highlight
 
 import (
 	"bytes"
+	"io"
 
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/formatters/html"
@@ -45,6 +46,16 @@ func (r *Renderer) Render(w util.BufWriter, source []byte, node ast.Node, enteri
 	}
 	code := buf.String()
 
+	if err := Highlight(w, code, lang); err != nil {
+		return ast.WalkStop, err
+	}
+
+	return ast.WalkSkipChildren, nil
+}
+
+// Highlight writes code as a highlighted code block. The language is guessed
+// from the code when lang is empty or unknown.
+func Highlight(w io.Writer, code, lang string) error {
 	var lexer chroma.Lexer
 	if lang != "" {
 		lexer = lexers.Get(lang)
@@ -66,7 +77,7 @@ func (r *Renderer) Render(w util.BufWriter, source []byte, node ast.Node, enteri
 
 	iterator, err := lexer.Tokenise(nil, code)
 	if err != nil {
-		return ast.WalkStop, err
+		return err
 	}
 
 	formatter := html.New(
@@ -81,17 +92,17 @@ func (r *Renderer) Render(w util.BufWriter, source []byte, node ast.Node, enteri
 	}
 
 	if err := formatter.Format(&codeBuf, style, iterator); err != nil {
-		return ast.WalkStop, err
+		return err
 	}
 
-	w.WriteString(`<div class="code-block" data-lang="` + langAttr + `">`)
-	w.WriteString(`<button class="copy-btn" aria-label="Copy code">Copy</button>`)
-	w.WriteString(`<pre class="chroma">`)
-	w.WriteString(`<code class="language-` + langAttr + `">`)
+	io.WriteString(w, `<div class="code-block" data-lang="`+langAttr+`">`)
+	io.WriteString(w, `<button class="copy-btn" aria-label="Copy code">Copy</button>`)
+	io.WriteString(w, `<pre class="chroma">`)
+	io.WriteString(w, `<code class="language-`+langAttr+`">`)
 	w.Write(codeBuf.Bytes())
-	w.WriteString(`</code>`)
-	w.WriteString(`</pre>`)
-	w.WriteString(`</div>`)
+	io.WriteString(w, `</code>`)
+	io.WriteString(w, `</pre>`)
+	io.WriteString(w, `</div>`)
 
-	return ast.WalkSkipChildren, nil
+	return nil
 }
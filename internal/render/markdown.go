@@ -2,10 +2,16 @@ package render
 
 import (
 	"bytes"
+	"fmt"
+	"geode/internal/cache"
 	"geode/internal/content"
 	"geode/internal/render/anchor"
+	"geode/internal/render/blockref"
 	"geode/internal/render/callout"
+	"geode/internal/render/codeembed"
+	"geode/internal/render/csvtable"
 	"geode/internal/render/externallink"
+	"geode/internal/render/headingid"
 	"geode/internal/render/highlight"
 	"geode/internal/render/mark"
 	"geode/internal/render/media"
@@ -14,12 +20,13 @@ import (
 	"geode/internal/render/wikilink"
 	"geode/internal/types"
 	"geode/internal/utils"
+	"log"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
-	"unicode"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
@@ -27,91 +34,162 @@ import (
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 	"gopkg.in/yaml.v3"
 )
 
-func ParsingMarkdown(entries []content.FileEntry) []types.MetaMarkdown {
-	pages := make([]types.MetaMarkdown, 0, len(entries))
-	urlToIndex := make(map[string]int, len(entries))
-	pendingBacklinks := make(map[string][]types.Link)
-	seenBacklinks := make(map[string]map[string]bool) // targetURL -> sourceURL -> seen
+func ParsingMarkdown(entries []content.FileEntry, jobs int) []types.MetaMarkdown {
+	pages := NewPipeline(entries, nil, false, nil, jobs, nil).Render(entries)
+	MergeBacklinks(pages)
+	return pages
+}
 
-	resolver := buildResolver(entries)
-	embedIndex := buildEmbedIndex(entries)
+// Pipeline renders notes against the link and embed indexes of a fixed set of
+// entries. It keeps one goldmark instance per worker between calls, so it must
+// not be used from several goroutines at once.
+type Pipeline struct {
+	index       *wikilink.Index
+	urls        map[string]string
+	headings    map[string][]headingid.Heading
+	unpublished *wikilink.Index
+	markPrivate bool
+	providers   *media.Providers
+	embed       embedResolver
+	jobs        int
+	renderers   []*noteRenderer
+	cache       *cache.Cache
+}
 
-	for _, entry := range entries {
-		if entry.IsAsset {
+// NewPipeline prepares a pipeline for entries. Links to the unpublished
+// notes are told apart from broken links, and marked as private when
+// markPrivate is set. Links to other sites in image syntax are embedded by
+// providers, the built-in ones if nil. The cache may be nil, in which case
+// every note is rendered.
+func NewPipeline(entries, unpublished []content.FileEntry, markPrivate bool, providers *media.Providers, jobs int, c *cache.Cache) *Pipeline {
+	index, embedIndex, urls, headings := buildResolver(entries)
+	draftIndex, _, _, _ := buildResolver(unpublished)
+
+	return &Pipeline{
+		index:       index,
+		urls:        urls,
+		headings:    headings,
+		unpublished: draftIndex,
+		markPrivate: markPrivate,
+		providers:   providers,
+		embed:       buildEmbedIndex(entries, embedIndex),
+		jobs:        jobs,
+		renderers:   make([]*noteRenderer, utils.Jobs(jobs)),
+		cache:       c,
+	}
+}
+
+// Refresh re-reads the headings of notes that changed on disk, so that links
+// to them point at their current headings. Changes to anything else a link
+// resolves through need a new pipeline.
+func (p *Pipeline) Refresh(notes []content.FileEntry) {
+	for _, entry := range notes {
+		if !entry.IsMarkdown {
 			continue
-		} else if entry.IsMarkdown {
+		}
 
-			contentBytes, err := os.ReadFile(entry.Path)
-			if err != nil {
-				continue
-			}
+		contentBytes, err := os.ReadFile(entry.Path)
+		if err != nil {
+			continue
+		}
+		_, body := extractFrontmatter(contentBytes)
+		p.headings[filepath.ToSlash(entry.RelativePath)] = headingid.Parse(body)
+	}
+}
 
-			frontmatter, body := extractFrontmatter(contentBytes)
-			title := ExtractTitle(frontmatter, entry)
-			link := ExtractPermalink(frontmatter, entry)
+// dependencies describes how the targets of lookups resolve now, in a stable
+// order and without repeats.
+func (p *Pipeline) dependencies(lookups []wikilink.Lookup) []cache.Dependency {
+	seen := make(map[wikilink.Lookup]bool, len(lookups))
+	deps := make([]cache.Dependency, 0, len(lookups))
+	for _, l := range lookups {
+		if seen[l] {
+			continue
+		}
+		seen[l] = true
+		deps = append(deps, cache.Dependency{From: l.From, Target: l.Target, Path: l.Path, Resolution: p.resolution(l)})
+	}
 
-			wordCount := CountWords(string(body))
-			readingTime := EstimateReadingTime(wordCount)
+	sort.Slice(deps, func(i, j int) bool {
+		a, b := deps[i], deps[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return !a.Path && b.Path
+	})
+	return deps
+}
 
-			htmlOut, outgoingLinks, toc, contentTags, hasKatex, hasMermaid := renderToHTML(body, resolver, embedIndex, entry.Path)
-			tags := mergeTags(parseFrontmatterTags(frontmatter), contentTags)
-			description := ExtractDescription(frontmatter, entry)
-			if description == "" {
-				description = utils.StripMarkdown(string(body))
-				if len(description) > 160 {
-					description = description[:160]
-				}
-			}
-			page := types.MetaMarkdown{
-				Path:            entry.Path,
-				RelativePath:    entry.RelativePath,
-				Link:            link,
-				Title:           title,
-				Frontmatter:     frontmatter,
-				Tags:            tags,
-				ReadingTime:     readingTime,
-				WordCount:       wordCount,
-				HTML:            htmlOut,
-				OutgoingLinks:   outgoingLinks,
-				TableOfContents: toc,
-				HasKatex:        hasKatex,
-				HasMermaid:      hasMermaid,
-				Description:     description,
-			}
+// upToDate reports whether every dependency of a cached note still resolves
+// the way it did when the note was rendered.
+func (p *Pipeline) upToDate(deps []cache.Dependency) bool {
+	for _, d := range deps {
+		if p.resolution(wikilink.Lookup{From: d.From, Target: d.Target, Path: d.Path}) != d.Resolution {
+			return false
+		}
+	}
+	return true
+}
 
-			pages = append(pages, page)
-			pageIndex := len(pages) - 1
-			if link != "" {
-				urlToIndex[link] = pageIndex
-				if pending, ok := pendingBacklinks[link]; ok {
-					pages[pageIndex].Backlinks = append(pages[pageIndex].Backlinks, pending...)
-					delete(pendingBacklinks, link)
-				}
-			}
+// resolution digests everything a link to the target of l renders from: the
+// file it resolves to and the other candidates, the URL and heading ids of
+// that file, the unpublished note a wikilink would point at instead and the
+// file it would embed.
+func (p *Pipeline) resolution(l wikilink.Lookup) string {
+	var m, draft, embed wikilink.Match
+	if l.Path {
+		m = p.index.ResolvePath(l.From, l.Target)
+	} else {
+		m = p.index.Resolve(l.From, l.Target)
+		draft = p.unpublished.Resolve(l.From, l.Target)
+		embed = p.embed.index.Resolve(l.From, l.Target)
+	}
 
-			sourceLink := types.Link{Title: title, URL: link}
-			for _, out := range outgoingLinks {
-				targetURL := out.URL
-				if targetURL == "" || targetURL == link {
-					continue
-				}
+	parts := append([]string{m.Path, p.urls[m.Path], draft.Path, embed.Path}, m.Candidates...)
+	parts = append(parts, embed.Candidates...)
+	for _, h := range p.headings[m.Path] {
+		parts = append(parts, "#"+h.ID)
+	}
+	return cache.Key([]byte(strings.Join(parts, "\n")))
+}
 
-				if _, ok := seenBacklinks[targetURL]; !ok {
-					seenBacklinks[targetURL] = make(map[string]bool)
-				}
-				if seenBacklinks[targetURL][sourceLink.URL] {
-					continue
-				}
-				seenBacklinks[targetURL][sourceLink.URL] = true
+// Render renders the markdown entries in parallel. Pages are returned in entry
+// order, notes that cannot be read are skipped.
+func (p *Pipeline) Render(entries []content.FileEntry) []types.MetaMarkdown {
+	notes := make([]content.FileEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsMarkdown {
+			notes = append(notes, entry)
+		}
+	}
 
-				if idx, ok := urlToIndex[targetURL]; ok {
-					pages[idx].Backlinks = append(pages[idx].Backlinks, sourceLink)
-				} else {
-					pendingBacklinks[targetURL] = append(pendingBacklinks[targetURL], sourceLink)
-				}
+	rendered := make([]*types.MetaMarkdown, len(notes))
+
+	utils.Parallel(len(notes), p.jobs, func(worker, i int) {
+		if p.renderers[worker] == nil {
+			p.renderers[worker] = newNoteRenderer(p.index, p.urls, p.headings, p.unpublished, p.markPrivate, p.providers, p.embed)
+		}
+
+		page, err := p.renderPage(p.renderers[worker], notes[i])
+		if err != nil {
+			return
+		}
+		rendered[i] = &page
+	})
+
+	pages := make([]types.MetaMarkdown, 0, len(notes))
+	for _, page := range rendered {
+		if page != nil {
+			pages = append(pages, *page)
+			for _, warning := range page.Warnings {
+				log.Printf("%s: %s", page.RelativePath, warning)
 			}
 		}
 	}
@@ -119,76 +197,109 @@ func ParsingMarkdown(entries []content.FileEntry) []types.MetaMarkdown {
 	return pages
 }
 
-type embedResolver struct {
-	Pages         map[string]string
-	ShortestPaths map[string]string
-}
-
-func buildEmbedIndex(entries []content.FileEntry) embedResolver {
-	pages := make(map[string]string)
-	shortestPaths := make(map[string]string)
-	baseNamePaths := make(map[string][]string)
-
-	for _, entry := range entries {
-		if !entry.IsMarkdown {
-			continue
+// MergeBacklinks recomputes the backlinks of every page from the outgoing
+// links of all pages.
+func MergeBacklinks(pages []types.MetaMarkdown) {
+	urlToIndex := make(map[string]int, len(pages))
+	for i, page := range pages {
+		pages[i].Backlinks = nil
+		if page.Link != "" {
+			urlToIndex[page.Link] = i
 		}
+	}
 
-		key := strings.TrimSuffix(entry.RelativePath, ".md")
-		key = filepath.ToSlash(key)
-		pages[key] = entry.Path
+	seenBacklinks := make(map[string]map[string]bool) // targetURL -> sourceURL -> seen
 
-		base := filepath.Base(key)
-		baseNamePaths[base] = append(baseNamePaths[base], key)
-	}
+	for _, page := range pages {
+		sourceLink := types.Link{Title: page.Title, URL: page.Link}
+		for _, out := range page.OutgoingLinks {
+			targetURL, _, _ := strings.Cut(out.URL, "#")
+			if targetURL == "" || targetURL == page.Link {
+				continue
+			}
 
-	for base, paths := range baseNamePaths {
-		shortestKey := paths[0]
-		for _, key := range paths {
-			if len(key) < len(shortestKey) {
-				shortestKey = key
+			if _, ok := seenBacklinks[targetURL]; !ok {
+				seenBacklinks[targetURL] = make(map[string]bool)
+			}
+			if seenBacklinks[targetURL][sourceLink.URL] {
+				continue
+			}
+			seenBacklinks[targetURL][sourceLink.URL] = true
+
+			if idx, ok := urlToIndex[targetURL]; ok {
+				pages[idx].Backlinks = append(pages[idx].Backlinks, sourceLink)
 			}
 		}
-		shortestPaths[base] = pages[shortestKey]
 	}
-
-	return embedResolver{Pages: pages, ShortestPaths: shortestPaths}
 }
 
-func (r embedResolver) resolve(target string) (string, bool) {
-	target = strings.TrimSuffix(target, ".md\\")
-	target = strings.TrimSuffix(target, ".md")
-	target = strings.Trim(target, "/")
-	target = filepath.ToSlash(target)
+// embedResolver resolves note embeds to the files they include.
+type embedResolver struct {
+	index *wikilink.Index
+	paths map[string]string // note relative to the content root -> file
+	files map[string]string // file shown from its contents, such as a CSV table -> file
+}
 
-	if dest, ok := r.Pages[target]; ok {
-		return dest, true
+func buildEmbedIndex(entries []content.FileEntry, index *wikilink.Index) embedResolver {
+	paths := make(map[string]string)
+	files := make(map[string]string)
+	for _, entry := range entries {
+		rel := filepath.ToSlash(entry.RelativePath)
+		switch {
+		case entry.IsMarkdown:
+			paths[rel] = entry.Path
+		case entry.IsText || isCSV(rel):
+			files[rel] = entry.Path
+		}
 	}
 
-	base := filepath.Base(target)
-	if dest, ok := r.ShortestPaths[base]; ok {
-		return dest, true
-	}
+	return embedResolver{index: index, paths: paths, files: files}
+}
+
+func isCSV(file string) bool {
+	return strings.EqualFold(filepath.Ext(file), ".csv")
+}
 
-	return "", false
+// resolve returns the note target refers to when embedded from the note at
+// from. Embeds of other files are left to the renderer.
+func (r embedResolver) resolve(from, target string) (string, wikilink.Match, bool) {
+	m := r.index.Resolve(from, target)
+	path, ok := r.paths[m.Path]
+	return path, m, ok
 }
 
-func expandMarkdownEmbeds(src []byte, r embedResolver, rootPath string) []byte {
+// resolveFile returns the CSV, code or text file target refers to when
+// embedded from the note at from.
+func (r embedResolver) resolveFile(from, target string) (string, wikilink.Match, bool) {
+	m := r.index.Resolve(from, target)
+	path, ok := r.files[m.Path]
+	return path, m, ok
+}
+
+// expandMarkdownEmbeds splices embedded notes into src. Embeds are rendered on
+// their own, the expanded source only identifies everything a note includes
+// for the cache. It also returns the paths of every note it included,
+// directly or through nested embeds, and warnings about embeds that matched
+// several notes.
+func expandMarkdownEmbeds(src []byte, r embedResolver, root content.FileEntry) ([]byte, []string, []string) {
 	if len(src) == 0 {
-		return src
+		return src, nil, nil
 	}
 
 	type segment struct {
 		b      []byte
 		i      int
+		from   string // the note b was read from, relative to the content root
 		onDone func()
 	}
 
-	const maxDepth = 20
 	depth := 0
 
-	includes := map[string]struct{}{rootPath: {}}
-	stack := []segment{{b: src}}
+	includes := map[string]struct{}{root.Path: {}}
+	stack := []segment{{b: src, from: filepath.ToSlash(root.RelativePath)}}
+
+	var embedded, warnings []string
+	seenEmbeds := make(map[string]struct{})
 
 	var out bytes.Buffer
 	out.Grow(len(src))
@@ -236,99 +347,198 @@ func expandMarkdownEmbeds(src []byte, r embedResolver, rootPath string) []byte {
 		}
 		inner = strings.TrimSpace(inner)
 
-		target := inner
-		var fragmentID string
-		if before, after, ok := strings.Cut(inner, "#"); ok {
-			target = strings.TrimSpace(before)
-			fragmentID = transformHeadingID(strings.TrimSpace(after))
-		}
+		target, fragment, _ := strings.Cut(inner, "#")
+		target = strings.TrimSpace(target)
 		if target == "" {
 			_, _ = out.Write(literal)
 			seg.i = j + 2
 			continue
 		}
 
-		path, ok := r.resolve(target)
+		path, m, ok := r.resolve(seg.from, target)
 		if !ok {
+			// Other files are shown from their contents, which the cache
+			// key has to cover.
 			_, _ = out.Write(literal)
+			if path, _, ok := r.resolveFile(seg.from, target); ok {
+				if data, err := os.ReadFile(path); err == nil {
+					_, _ = out.Write(data)
+				}
+				if _, ok := seenEmbeds[path]; !ok {
+					seenEmbeds[path] = struct{}{}
+					embedded = append(embedded, path)
+				}
+			}
 			seg.i = j + 2
 			continue
 		}
+		if m.Ambiguous() {
+			warnings = append(warnings, wikilink.AmbiguousWarning(target, m))
+		}
 
-		if _, seen := includes[path]; seen || depth >= maxDepth {
+		if _, seen := includes[path]; seen || depth >= maxEmbedDepth {
 			seg.i = j + 2
 			continue
 		}
 
-		contentBytes, err := os.ReadFile(path)
-		if err != nil {
+		front, body, ok := readEmbed(path, fragment)
+		if !ok {
 			_, _ = out.Write(literal)
 			seg.i = j + 2
 			continue
 		}
 
-		_, body := extractFrontmatter(contentBytes)
-		if fragmentID != "" {
-			section, ok := extractMarkdownSection(body, fragmentID)
-			if !ok {
-				_, _ = out.Write(literal)
-				seg.i = j + 2
-				continue
-			}
-			body = section
-		}
+		// The transclusion header shows the title of the embedded note.
+		_, _ = out.WriteString("\x00" + ExtractTitle(front, content.FileEntry{Path: path, RelativePath: m.Path}) + "\x00")
 		includes[path] = struct{}{}
 		depth++
 
+		if _, ok := seenEmbeds[path]; !ok {
+			seenEmbeds[path] = struct{}{}
+			embedded = append(embedded, path)
+		}
+
 		seg.i = j + 2
-		stack = append(stack, segment{b: body, onDone: func() {
+		stack = append(stack, segment{b: body, from: m.Path, onDone: func() {
 			delete(includes, path)
 			depth--
 		}})
 	}
 
-	return out.Bytes()
+	return out.Bytes(), embedded, warnings
+}
+
+const maxEmbedDepth = 20
+
+// readEmbed reads the part of the note at path an embed with the given
+// fragment includes: the whole body, the section under a heading, or a single
+// block.
+func readEmbed(path, fragment string) (map[string]any, []byte, bool) {
+	contentBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	front, body := extractFrontmatter(contentBytes)
+
+	fragment = strings.TrimSpace(fragment)
+	if id, ok := strings.CutPrefix(fragment, "^"); ok {
+		body, ok = extractMarkdownBlock(body, id)
+		return front, body, ok
+	}
+	if fragment != "" {
+		body, ok := extractMarkdownSection(body, fragment)
+		return front, body, ok
+	}
+
+	return front, body, true
 }
 
-func extractMarkdownSection(body []byte, fragmentID string) ([]byte, bool) {
-	if fragmentID == "" || len(body) == 0 {
+// extractMarkdownSection returns the section under the heading fragment
+// refers to, up to the next heading of the same or a higher level.
+func extractMarkdownSection(body []byte, fragment string) ([]byte, bool) {
+	h, ok := headingid.Match(headingid.Parse(body), fragment)
+	if !ok {
 		return nil, false
 	}
+	return bytes.TrimRight(body[h.Start:h.End], "\n"), true
+}
 
-	lines := strings.Split(string(body), "\n")
+// extractMarkdownBlock returns the block marked with the block id ^id: the
+// paragraph or list item ending in it, or the block right before a line that
+// holds nothing but the id. The marker itself is left out.
+func extractMarkdownBlock(body []byte, id string) ([]byte, bool) {
+	if !blockref.Valid(id) || len(body) == 0 {
+		return nil, false
+	}
 
-	startLine := -1
-	startLevel := 0
+	marker := blockref.ID(id)
+	lines := strings.Split(string(body), "\n")
 
 	for i, line := range lines {
-		level, text, ok := parseATXHeading(line)
-		if !ok {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == marker {
+			// Skip the blank lines between the block and its marker.
+			end := i
+			for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+			start := end
+			for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+				start--
+			}
+			if start == end {
+				return nil, false
+			}
+			return []byte(strings.Join(lines[start:end], "\n")), true
+		}
+
+		if !strings.HasSuffix(trimmed, " "+marker) {
 			continue
 		}
-		if transformHeadingID(text) == fragmentID {
-			startLine = i
-			startLevel = level
-			break
+		lines[i] = strings.TrimRight(line[:strings.LastIndex(line, marker)], " \t")
+
+		// A list item brings its nested lines along.
+		if indent, ok := listItemIndent(line); ok {
+			end := i + 1
+			for end < len(lines) {
+				next := lines[end]
+				if strings.TrimSpace(next) != "" && leadingSpaces(next) <= indent {
+					break
+				}
+				end++
+			}
+			for end > i+1 && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+			return []byte(strings.Join(lines[i:end], "\n")), true
+		}
+
+		start := i
+		for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+			if _, _, ok := parseATXHeading(lines[start-1]); ok {
+				break
+			}
+			start--
 		}
+		return []byte(strings.Join(lines[start:i+1], "\n")), true
 	}
-	if startLine < 0 {
-		return nil, false
+
+	return nil, false
+}
+
+func listItemIndent(line string) (int, bool) {
+	indent := leadingSpaces(line)
+	rest := strings.TrimLeft(line, " \t")
+
+	if len(rest) >= 2 && strings.ContainsRune("-*+", rune(rest[0])) && rest[1] == ' ' {
+		return indent, true
 	}
 
-	endLine := len(lines)
-	for i := startLine + 1; i < len(lines); i++ {
-		level, _, ok := parseATXHeading(lines[i])
-		if !ok {
-			continue
-		}
-		if level <= startLevel {
-			endLine = i
-			break
-		}
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && digits+1 < len(rest) && (rest[digits] == '.' || rest[digits] == ')') && rest[digits+1] == ' ' {
+		return indent, true
 	}
 
-	section := strings.Join(lines[startLine:endLine], "\n")
-	return []byte(section), true
+	return 0, false
+}
+
+func leadingSpaces(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
 }
 
 func parseATXHeading(line string) (level int, text string, ok bool) {
@@ -358,100 +568,309 @@ func parseATXHeading(line string) (level int, text string, ok bool) {
 	return level, text, true
 }
 
-func transformHeadingID(text string) string {
-	text = strings.TrimSpace(text)
-	text = strings.TrimLeft(text, "#")
-	text = strings.TrimSpace(text)
-
-	var result strings.Builder
-
-	for _, r := range text {
-		if unicode.IsLetter(r) || unicode.IsDigit(r) {
-			result.WriteRune(unicode.ToLower(r))
-		} else if unicode.IsSpace(r) || r == '-' || r == '_' {
-			result.WriteRune('-')
-		}
-	}
-
-	id := result.String()
-	id = strings.Trim(id, "-")
-	return id
-}
-
-func buildResolver(entries []content.FileEntry) wikilink.Resolver {
-	pages := make(map[string]string)
-	shortestPaths := make(map[string]string)
-	baseNamePaths := make(map[string][]string)
+// buildResolver indexes the entries and the aliases of every note for link
+// resolution, and maps each entry to its URL and each note to its headings.
+// Text files are published only when embedded, so they are left out of the
+// index for links and indexed along with everything else for embeds.
+func buildResolver(entries []content.FileEntry) (*wikilink.Index, *wikilink.Index, map[string]string, map[string][]headingid.Heading) {
+	files := make([]string, 0, len(entries))
+	linked := make([]string, 0, len(entries))
+	urls := make(map[string]string, len(entries))
+	aliases := make(map[string][]string)
+	headings := make(map[string][]headingid.Heading)
 
 	for _, entry := range entries {
-		key := strings.TrimSuffix(entry.RelativePath, ".md")
-		key = filepath.ToSlash(key)
+		file := filepath.ToSlash(entry.RelativePath)
+		files = append(files, file)
+		if !entry.IsText {
+			linked = append(linked, file)
+		}
 
-		link := utils.PathToSlug(entry.RelativePath)
-		link = "/" + strings.TrimSuffix(link, ".md")
+		if !entry.IsMarkdown {
+			urls[file] = "/" + utils.AssetPath(entry.RelativePath)
+			continue
+		}
 
-		pages[key] = link
+		var front map[string]any
+		if contentBytes, err := os.ReadFile(entry.Path); err == nil {
+			var body []byte
+			front, body = extractFrontmatter(contentBytes)
+			headings[file] = headingid.Parse(body)
+		}
 
-		base := filepath.Base(key)
-		baseNamePaths[base] = append(baseNamePaths[base], key)
+		urls[file] = ExtractPermalink(front, entry)
+		aliases[file] = ExtractAliases(front)
 	}
 
-	for base, paths := range baseNamePaths {
-		shortestPath := paths[0]
-		for _, path := range paths {
-			if len(path) < len(shortestPath) {
-				shortestPath = path
-			}
+	links, embeds := wikilink.NewIndex(linked), wikilink.NewIndex(files)
+	for file, names := range aliases {
+		for _, alias := range names {
+			links.AddAlias(alias, file)
+			embeds.AddAlias(alias, file)
 		}
-		shortestPaths[base] = pages[shortestPath]
 	}
 
-	return wikilink.PageResolver{
-		Pages:         pages,
-		ShortestPaths: shortestPaths,
-	}
+	return links, embeds, urls, headings
 }
 
-func renderToHTML(source []byte, resolver wikilink.Resolver, embed embedResolver, rootPath string) (string, []types.Link, []types.TocItem, []string, bool, bool) {
-	collector := wikilink.NewLinkCollector(resolver)
-	tagCollector := hashtag.NewCollector()
-	toc := make([]types.TocItem, 0)
-	tagResolver := hashtag.Resolver(tagLinkResolver{})
+type noteRenderer struct {
+	md        goldmark.Markdown
+	embed     embedResolver
+	resolver  *wikilink.PageResolver
+	collector *wikilink.LinkCollector
+	tags      *hashtag.Collector
+	toc       []types.TocItem
+	ids       *headingid.IDs
+	source    []byte // of the note being rendered
+
+	markPrivate bool
+	providers   *media.Providers
+
+	from  string   // the note being rendered, relative to the content root
+	stack []string // files of the note and of the notes embedding it
+
+	// child renders the notes this one embeds.
+	child        *noteRenderer
+	embedKatex   bool
+	embedMermaid bool
+}
 
-	source = expandMarkdownEmbeds(source, embed, rootPath)
-	context := parser.NewContext()
+func newNoteRenderer(index *wikilink.Index, urls map[string]string, headings map[string][]headingid.Heading, unpublished *wikilink.Index, markPrivate bool, providers *media.Providers, embed embedResolver) *noteRenderer {
+	resolver := &wikilink.PageResolver{Index: index, URLs: urls, Headings: headings, Unpublished: unpublished}
+	r := &noteRenderer{
+		embed:       embed,
+		resolver:    resolver,
+		collector:   wikilink.NewLinkCollector(resolver),
+		tags:        hashtag.NewCollector(),
+		markPrivate: markPrivate,
+		providers:   providers,
+	}
 
-	md := goldmark.New(
+	r.md = goldmark.New(
 		goldmark.WithExtensions(
 			extension.GFM,
 			extension.Strikethrough,
 			extension.Table,
 			extension.TaskList,
 			extension.Footnote,
-			&media.Extender{},
+			&media.Extender{Providers: providers},
 			&wikilink.Extender{
 				Resolver:  resolver,
-				Collector: collector,
+				Collector: r.collector,
+				Embedder:  r,
+
+				MarkPrivate: markPrivate,
 			},
 			&hashtag.Extender{
-				Collector: tagCollector,
-				Resolver:  tagResolver,
+				Collector: r.tags,
+				Resolver:  tagLinkResolver{},
 			},
 			&mermaid.Extender{},
 			&highlight.Extender{},
 			&callout.Extender{},
 			&anchor.Extender{},
 			&mark.Extender{},
+			&blockref.Extender{},
 			&externallink.Extender{},
 		),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
-			withHeadingShiftAndTOC(1, &toc),
+			withHeadingShiftAndTOC(1, &r.toc),
 		),
 		goldmark.WithRendererOptions(html.WithUnsafe()),
 	)
 
-	doc := md.Parser().Parse(text.NewReader(source), parser.WithContext(context))
+	return r
+}
+
+// reset prepares r for the note at from, embedded through the files on stack.
+// Its headings take their ids from ids, which embedded notes share with the
+// page they are shown on.
+func (r *noteRenderer) reset(from string, stack []string, ids *headingid.IDs) {
+	r.from = from
+	r.stack = stack
+	r.ids = ids
+	r.resolver.Reset(from)
+}
+
+// EmbedNote renders the note n embeds with a renderer of its own, so that its
+// headings, links and tags stay out of the embedding note. Embedded CSV,
+// code and text files are rendered from their contents.
+func (r *noteRenderer) EmbedNote(n *wikilink.Node) ([]byte, bool) {
+	target := strings.TrimSpace(string(n.Target))
+	r.resolver.Record(wikilink.Lookup{From: r.from, Target: target})
+
+	path, m, ok := r.embed.resolve(r.from, target)
+	if !ok {
+		return r.embedFile(n)
+	}
+
+	// Embeds that would recurse render as nothing.
+	if slices.Contains(r.stack, path) || len(r.stack) > maxEmbedDepth {
+		return []byte{}, true
+	}
+
+	front, body, ok := readEmbed(path, string(n.Fragment))
+	if !ok {
+		return nil, false
+	}
+
+	if r.child == nil {
+		r.child = newNoteRenderer(r.resolver.Index, r.resolver.URLs, r.resolver.Headings, r.resolver.Unpublished, r.markPrivate, r.providers, r.embed)
+	}
+	r.child.reset(m.Path, append(slices.Clone(r.stack), path), r.ids)
+
+	htmlOut, _, _, _, hasKatex, hasMermaid := r.child.renderToHTML(body)
+	r.resolver.Adopt(r.child.resolver)
+	r.embedKatex = r.embedKatex || hasKatex
+	r.embedMermaid = r.embedMermaid || hasMermaid
+
+	title := ExtractTitle(front, content.FileEntry{Path: path, RelativePath: m.Path})
+	href := r.resolver.URL(m.Path, string(n.Fragment))
+
+	var buf bytes.Buffer
+	buf.WriteString(`<div class="transclusion"><div class="transclusion-header"><a class="transclusion-link" href="`)
+	buf.Write(util.URLEscape(href, true))
+	buf.WriteString(`">`)
+	buf.Write(util.EscapeHTML([]byte(title)))
+	buf.WriteString(`</a></div><div class="transclusion-content">`)
+	buf.WriteString(htmlOut)
+	buf.WriteString("</div></div>\n")
+
+	return buf.Bytes(), true
+}
+
+// embedFile renders an embedded CSV file as a table, and any other file it
+// knows as a highlighted code block. Files that cannot be read or parsed are
+// linked to instead.
+func (r *noteRenderer) embedFile(n *wikilink.Node) ([]byte, bool) {
+	path, m, ok := r.embed.resolveFile(r.from, strings.TrimSpace(string(n.Target)))
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var label string
+	if n.Labeled && n.ChildCount() == 1 {
+		if t, ok := n.FirstChild().(*ast.Text); ok {
+			label = string(t.Value(r.source))
+		}
+	}
+
+	name := filepath.Base(m.Path)
+	href := string(r.resolver.URL(m.Path, ""))
+
+	var out []byte
+	if isCSV(m.Path) {
+		out, err = csvtable.Render(data, name, href, csvtable.ParseOptions(label))
+	} else {
+		var rng codeembed.Range
+		if rng, err = codeembed.ParseRange(string(n.Fragment)); err == nil {
+			out, err = codeembed.Render(data, name, href, rng)
+		}
+	}
+	if err != nil {
+		r.resolver.Warn(fmt.Sprintf("embed %q: %v", n.Target, err))
+		return nil, false
+	}
+	return out, true
+}
+
+func (p *Pipeline) renderPage(r *noteRenderer, entry content.FileEntry) (types.MetaMarkdown, error) {
+	contentBytes, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return types.MetaMarkdown{}, err
+	}
+
+	frontmatter, body := extractFrontmatter(contentBytes)
+	title := ExtractTitle(frontmatter, entry)
+	link := ExtractPermalink(frontmatter, entry)
+
+	wordCount := CountWords(string(body))
+	readingTime := EstimateReadingTime(wordCount)
+
+	// The expanded source already contains everything the note embeds, so it
+	// is all the key needs besides the note's identity. How its links
+	// resolve is checked against the dependencies of the entry.
+	expanded, embeds, warnings := expandMarkdownEmbeds(body, r.embed, entry)
+	key := cache.Key([]byte(filepath.ToSlash(entry.RelativePath)), expanded)
+
+	cached, ok := p.cache.Get(key)
+	if !ok || !p.upToDate(cached.Dependencies) {
+		r.reset(filepath.ToSlash(entry.RelativePath), []string{entry.Path}, headingid.New())
+		htmlOut, outgoingLinks, toc, contentTags, hasKatex, hasMermaid := r.renderToHTML(body)
+		cached = cache.Entry{
+			HTML:          htmlOut,
+			OutgoingLinks: outgoingLinks,
+			Toc:           toc,
+			Tags:          contentTags,
+			HasKatex:      hasKatex,
+			HasMermaid:    hasMermaid,
+			Warnings:      r.resolver.Warnings(),
+
+			UnresolvedLinks: unresolvedLinks(r.resolver.Unresolved()),
+			Dependencies:    p.dependencies(r.resolver.Lookups()),
+		}
+		if err := p.cache.Put(key, cached); err != nil {
+			log.Printf("cache write %s: %v", entry.RelativePath, err)
+		}
+	}
+
+	tags := mergeTags(parseFrontmatterTags(frontmatter), cached.Tags)
+	description := ExtractDescription(frontmatter, entry)
+	if description == "" {
+		description = utils.StripMarkdown(string(body))
+		if len(description) > 160 {
+			description = description[:160]
+		}
+	}
+
+	return types.MetaMarkdown{
+		Path:            entry.Path,
+		RelativePath:    entry.RelativePath,
+		Link:            link,
+		Title:           title,
+		Frontmatter:     frontmatter,
+		Tags:            tags,
+		ReadingTime:     readingTime,
+		WordCount:       wordCount,
+		HTML:            cached.HTML,
+		OutgoingLinks:   cached.OutgoingLinks,
+		Embeds:          embeds,
+		TableOfContents: cached.Toc,
+		HasKatex:        cached.HasKatex,
+		HasMermaid:      cached.HasMermaid,
+		Description:     description,
+		Aliases:         ExtractAliases(frontmatter),
+		RedirectFrom:    frontmatterStrings(frontmatter, "redirect_from"),
+		Warnings:        append(warnings, cached.Warnings...),
+		UnresolvedLinks: cached.UnresolvedLinks,
+	}, nil
+}
+
+func unresolvedLinks(links []wikilink.Unresolved) []types.UnresolvedLink {
+	out := make([]types.UnresolvedLink, len(links))
+	for i, link := range links {
+		out[i] = types.UnresolvedLink{Target: link.Target, Unpublished: link.Unpublished, Embedded: link.Embedded}
+	}
+	return out
+}
+
+func (r *noteRenderer) renderToHTML(source []byte) (string, []types.Link, []types.TocItem, []string, bool, bool) {
+	r.collector.Reset()
+	r.tags.Reset()
+	r.toc = make([]types.TocItem, 0)
+	r.embedKatex = false
+	r.embedMermaid = false
+	r.source = source
+
+	context := parser.NewContext(parser.WithIDs(r.ids))
+
+	doc := r.md.Parser().Parse(text.NewReader(source), parser.WithContext(context))
 
 	// Check if the document contains katex
 	hasKatex := false
@@ -478,11 +897,11 @@ func renderToHTML(source []byte, resolver wikilink.Resolver, embed embedResolver
 	})
 
 	var buf bytes.Buffer
-	if err := md.Renderer().Render(&buf, source, doc); err != nil {
+	if err := r.md.Renderer().Render(&buf, source, doc); err != nil {
 		return "", nil, nil, nil, false, false
 	}
 
-	collectedLinks := collector.GetLinks()
+	collectedLinks := r.collector.GetLinks()
 	links := make([]types.Link, len(collectedLinks))
 	for i, link := range collectedLinks {
 		links[i] = types.Link{
@@ -491,7 +910,7 @@ func renderToHTML(source []byte, resolver wikilink.Resolver, embed embedResolver
 		}
 	}
 
-	return buf.String(), links, toc, tagCollector.Tags(), hasKatex, mermaid.GetHasMermaid(context)
+	return buf.String(), links, r.toc, r.tags.Tags(), hasKatex || r.embedKatex, mermaid.GetHasMermaid(context) || r.embedMermaid
 }
 
 type tagLinkResolver struct{}
@@ -653,6 +1072,29 @@ func ExtractPermalink(front map[string]any, entry content.FileEntry) string {
 	return url
 }
 
+func ExtractAliases(front map[string]any) []string {
+	return frontmatterStrings(front, "aliases")
+}
+
+// frontmatterStrings reads a field that holds either a single string or a
+// list of strings.
+func frontmatterStrings(front map[string]any, key string) []string {
+	var out []string
+	switch v := front[key].(type) {
+	case string:
+		if s := strings.TrimSpace(v); s != "" {
+			out = append(out, s)
+		}
+	case []any:
+		for _, it := range v {
+			if s, ok := it.(string); ok && strings.TrimSpace(s) != "" {
+				out = append(out, strings.TrimSpace(s))
+			}
+		}
+	}
+	return out
+}
+
 func CountWords(text string) int {
 	words := strings.Fields(text)
 	return len(words)
@@ -0,0 +1,130 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"geode/internal/content"
+	"geode/internal/types"
+)
+
+// writeVault writes files, keyed by slash separated path, into a temporary
+// directory and returns their entries sorted by path.
+func writeVault(t *testing.T, files map[string]string) []content.FileEntry {
+	t.Helper()
+
+	dir := t.TempDir()
+	var entries []content.FileEntry
+	for rel, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		ext := strings.ToLower(filepath.Ext(rel))
+		entries = append(entries, content.FileEntry{
+			Path:         path,
+			RelativePath: filepath.FromSlash(rel),
+			Size:         int64(len(data)),
+			IsMarkdown:   ext == ".md",
+			IsAsset:      ext == ".png",
+			IsText:       ext == ".go" || ext == ".yaml",
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RelativePath < entries[j].RelativePath })
+	return entries
+}
+
+func TestRenderOrder(t *testing.T) {
+	files := map[string]string{"img.png": "png"}
+	for i := range 50 {
+		files[fmt.Sprintf("n%02d.md", i)] = fmt.Sprintf("# Note %d\n\nSee [[n%02d]].\n", i, (i+1)%50)
+	}
+	entries := writeVault(t, files)
+
+	pages := NewPipeline(entries, nil, false, nil, 8, nil).Render(entries)
+	if len(pages) != 50 {
+		t.Fatalf("got %d pages, want 50", len(pages))
+	}
+
+	for i, page := range pages {
+		if want := fmt.Sprintf("n%02d.md", i); page.RelativePath != want {
+			t.Fatalf("page %d is %s, want %s", i, page.RelativePath, want)
+		}
+		if link := fmt.Sprintf(`href="/n%02d"`, (i+1)%50); !strings.Contains(page.HTML, link) {
+			t.Errorf("%s lacks %s:\n%s", page.RelativePath, link, page.HTML)
+		}
+	}
+}
+
+func TestEmbedReportsProblems(t *testing.T) {
+	entries := writeVault(t, map[string]string{
+		"a.md":     "![[b]]\n\n[[Gone]]\n",
+		"b.md":     "[[Missing]] and [[Dup]]\n\n![[c]]\n",
+		"c.md":     "![](nope.png)\n",
+		"Dup.md":   "One.\n",
+		"x/Dup.md": "Two.\n",
+	})
+
+	pages := NewPipeline(entries, nil, false, nil, 1, nil).Render(entries)
+	var page types.MetaMarkdown
+	for _, p := range pages {
+		if p.RelativePath == "a.md" {
+			page = p
+		}
+	}
+
+	wantLinks := []types.UnresolvedLink{{Target: "Missing", Embedded: "b.md"}, {Target: "Gone"}}
+	if !slices.Equal(page.UnresolvedLinks, wantLinks) {
+		t.Errorf("unresolved links = %+v, want %+v", page.UnresolvedLinks, wantLinks)
+	}
+
+	wantWarnings := []string{
+		`embedded b.md: ambiguous link "Dup" matches Dup.md, x/Dup.md, using Dup.md`,
+		`embedded c.md: missing asset "nope.png"`,
+	}
+	if !slices.Equal(page.Warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", page.Warnings, wantWarnings)
+	}
+}
+
+func TestLinksToTextFiles(t *testing.T) {
+	entries := writeVault(t, map[string]string{
+		"a.md":        "[[script.go]] and [config](config.yaml)\n\n![[script.go]]\n",
+		"script.go":   "package script\n",
+		"config.yaml": "key: value\n",
+	})
+	p := NewPipeline(entries, nil, false, nil, 1, nil)
+
+	// Text files are only published when embedded, so plain links to them
+	// have nothing to point at.
+	page := p.Render(entries)[0]
+	wantLinks := []types.UnresolvedLink{{Target: "script.go"}}
+	if !slices.Equal(page.UnresolvedLinks, wantLinks) {
+		t.Errorf("unresolved links = %+v, want %+v", page.UnresolvedLinks, wantLinks)
+	}
+	if want := []string{`missing asset "config.yaml"`}; !slices.Equal(page.Warnings, want) {
+		t.Errorf("warnings = %q, want %q", page.Warnings, want)
+	}
+	if !strings.Contains(page.HTML, `<a href="/script.go">script.go</a>`) {
+		t.Errorf("embed of script.go not rendered:\n%s", page.HTML)
+	}
+
+	var got []string
+	for _, problem := range p.Check(entries, false) {
+		got = append(got, problem.Message)
+	}
+	want := []string{`unresolved link [[script.go]]`, `unresolved link "config.yaml"`}
+	if !slices.Equal(got, want) {
+		t.Errorf("problems = %q, want %q", got, want)
+	}
+}
@@ -6,14 +6,16 @@ import (
 	"github.com/yuin/goldmark/util"
 )
 
-type Extender struct{}
+type Extender struct {
+	Providers *Providers
+}
 
 var _ goldmark.Extender = (*Extender)(nil)
 
 func (e *Extender) Extend(m goldmark.Markdown) {
 	m.Renderer().AddOptions(
 		renderer.WithNodeRenderers(
-			util.Prioritized(&Renderer{}, 999),
+			util.Prioritized(&Renderer{Providers: e.Providers}, 999),
 		),
 	)
 }
@@ -0,0 +1,257 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"geode/internal/config"
+	"geode/internal/render/embedopt"
+	"html/template"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// builtinProviders are the providers every site has. A provider configured
+// with the same name replaces one of them, or removes it when it has no
+// pattern.
+var builtinProviders = []config.EmbedProvider{
+	{
+		Name:     "YouTube Shorts",
+		Pattern:  `^https?://(?:www\.|m\.)?youtube\.com/shorts/(?P<id>[\w-]+)`,
+		Template: `<iframe src="https://www.youtube.com/embed/{{.Group.id}}" width="{{.Width}}" height="{{.Height}}" title="{{.Title}}" frameborder="0" allow="accelerometer; autoplay; clipboard-write; encrypted-media; gyroscope; picture-in-picture; web-share" allowfullscreen loading="lazy"></iframe>`,
+		Width:    315,
+		Height:   560,
+	},
+	{
+		Name:     "YouTube",
+		Pattern:  `^https?://(?:(?:www\.|m\.)?youtube\.com/(?:watch\?(?:[^#]*&)?v=|embed/)|youtu\.be/)(?P<id>[\w-]+)`,
+		Template: `<iframe src="https://www.youtube.com/embed/{{.Group.id}}" width="{{.Width}}" height="{{.Height}}" title="{{.Title}}" frameborder="0" allow="accelerometer; autoplay; clipboard-write; encrypted-media; gyroscope; picture-in-picture; web-share" allowfullscreen loading="lazy"></iframe>`,
+		Width:    560,
+		Height:   315,
+	},
+	{
+		Name:     "X",
+		Pattern:  `^https?://(?:www\.)?(?:x|twitter)\.com/(?P<user>\w+)/status/(?P<id>\d+)`,
+		Template: `<blockquote class="twitter-tweet"><a href="https://twitter.com/{{.Group.user}}/status/{{.Group.id}}">@{{.Group.user}}</a></blockquote>`,
+	},
+	{
+		Name:     "Vimeo",
+		Pattern:  `^https?://(?:www\.)?vimeo\.com/(?:video/)?(?P<id>\d+)`,
+		Template: `<iframe src="https://player.vimeo.com/video/{{.Group.id}}" width="{{.Width}}" height="{{.Height}}" title="{{.Title}}" frameborder="0" allow="autoplay; fullscreen; picture-in-picture" allowfullscreen loading="lazy"></iframe>`,
+		Width:    640,
+		Height:   360,
+	},
+	{
+		Name:     "Spotify",
+		Pattern:  `^https?://open\.spotify\.com/(?:intl-[\w-]+/)?(?P<type>track|album|playlist|episode|show|artist)/(?P<id>\w+)`,
+		Template: `<iframe src="https://open.spotify.com/embed/{{.Group.type}}/{{.Group.id}}" {{if .Width}}width="{{.Width}}"{{else}}width="100%"{{end}} height="{{.Height}}" title="{{.Title}}" frameborder="0" allow="autoplay; clipboard-write; encrypted-media; fullscreen; picture-in-picture" loading="lazy"></iframe>`,
+		Height:   352,
+	},
+	{
+		Name:     "SoundCloud",
+		Pattern:  `^https?://(?:www\.|m\.)?soundcloud\.com/[\w-]+/[\w-]+`,
+		Template: `<iframe src="https://w.soundcloud.com/player/?url={{.URL}}" {{if .Width}}width="{{.Width}}"{{else}}width="100%"{{end}} height="{{.Height}}" title="{{.Title}}" frameborder="no" scrolling="no" allow="autoplay" loading="lazy"></iframe>`,
+		Height:   166,
+	},
+	{
+		Name:     "CodePen",
+		Pattern:  `^https?://codepen\.io/(?P<user>[\w-]+)/(?:pen|full|details)/(?P<id>\w+)`,
+		Template: `<iframe src="https://codepen.io/{{.Group.user}}/embed/{{.Group.id}}?default-tab=result" {{if .Width}}width="{{.Width}}"{{else}}width="100%"{{end}} height="{{.Height}}" title="{{.Title}}" frameborder="no" allowfullscreen loading="lazy"></iframe>`,
+		Height:   400,
+	},
+	{
+		// Gists are written by a script with document.write, which only
+		// works in a document of its own.
+		Name:     "GitHub Gist",
+		Pattern:  `^https?://gist\.github\.com/(?P<user>[\w-]+)/(?P<id>[0-9a-fA-F]+)`,
+		Template: `<iframe class="gist-embed" srcdoc="<base target=&quot;_blank&quot;><script src=&quot;https://gist.github.com/{{.Group.user}}/{{.Group.id}}.js&quot;></script>" {{if .Width}}width="{{.Width}}"{{else}}width="100%"{{end}} height="{{.Height}}" title="{{.Title}}" frameborder="0" loading="lazy"></iframe>`,
+		Height:   400,
+	},
+	{
+		Name:     "Loom",
+		Pattern:  `^https?://(?:www\.)?loom\.com/(?:share|embed)/(?P<id>\w+)`,
+		Template: `<iframe src="https://www.loom.com/embed/{{.Group.id}}" width="{{.Width}}" height="{{.Height}}" title="{{.Title}}" frameborder="0" allowfullscreen loading="lazy"></iframe>`,
+		Width:    640,
+		Height:   360,
+	},
+}
+
+type provider struct {
+	name          string
+	pattern       *regexp.Regexp
+	tmpl          *template.Template
+	width, height int
+}
+
+// Providers turn links to other sites in image syntax, as in
+// ![](https://vimeo.com/76979871), into embedded content.
+type Providers struct {
+	list []provider
+
+	// ClickToLoad shows a placeholder in place of the content, which is
+	// only requested from the other site when the reader asks for it.
+	ClickToLoad bool
+}
+
+// ProviderData is what a provider template is executed with.
+type ProviderData struct {
+	URL    string            // the embedded link
+	Match  []string          // the text Pattern matched and its groups
+	Group  map[string]string // the named groups
+	Width  int               // set by the embed or by the provider, zero if neither
+	Height int
+	Title  string // alt text of the embed, or the provider name
+}
+
+// NewProviders compiles the configured providers, which take precedence
+// over the built-in ones.
+func NewProviders(configured []config.EmbedProvider, clickToLoad bool) (*Providers, error) {
+	p := &Providers{ClickToLoad: clickToLoad}
+
+	names := make(map[string]struct{}, len(configured))
+	for _, c := range configured {
+		names[strings.ToLower(c.Name)] = struct{}{}
+	}
+
+	all := slices.Clone(configured)
+	for _, c := range builtinProviders {
+		if _, ok := names[strings.ToLower(c.Name)]; !ok {
+			all = append(all, c)
+		}
+	}
+
+	for _, c := range all {
+		if c.Pattern == "" {
+			continue
+		}
+
+		pattern, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("embed provider %s: %w", c.Name, err)
+		}
+		tmpl, err := template.New(c.Name).Option("missingkey=zero").Parse(c.Template)
+		if err != nil {
+			return nil, fmt.Errorf("embed provider %s: %w", c.Name, err)
+		}
+
+		p.list = append(p.list, provider{
+			name:    c.Name,
+			pattern: pattern,
+			tmpl:    tmpl,
+			width:   c.Width,
+			height:  c.Height,
+		})
+	}
+
+	return p, nil
+}
+
+var defaultProviders *Providers
+
+func init() {
+	var err error
+	if defaultProviders, err = NewProviders(nil, false); err != nil {
+		panic(err)
+	}
+}
+
+// DefaultProviders returns the built-in providers.
+func DefaultProviders() *Providers {
+	return defaultProviders
+}
+
+// render writes the embed of the first provider matching dest. It reports
+// false when none does.
+func (p *Providers) render(w util.BufWriter, dest []byte, opts embedopt.Options) bool {
+	link := strings.TrimSpace(string(dest))
+
+	for _, pr := range p.list {
+		match := pr.pattern.FindStringSubmatch(link)
+		if match == nil {
+			continue
+		}
+
+		data := ProviderData{
+			URL:   link,
+			Match: match,
+			Group: make(map[string]string),
+			Title: opts.Alt,
+		}
+		for i, name := range pr.pattern.SubexpNames() {
+			if name != "" {
+				data.Group[name] = match[i]
+			}
+		}
+		if data.Title == "" {
+			data.Title = pr.name
+		}
+		data.Width, data.Height = pr.size(opts)
+
+		var buf bytes.Buffer
+		if err := pr.tmpl.Execute(&buf, data); err != nil {
+			continue
+		}
+
+		opts.OpenFigure(w)
+		if p.ClickToLoad {
+			writePlaceholder(w, pr.name, link, buf.Bytes(), data.Width, data.Height)
+		} else {
+			_, _ = w.Write(buf.Bytes())
+		}
+		opts.CloseFigure(w)
+		return true
+	}
+
+	return false
+}
+
+// size returns the size of an embed. A width alone keeps the proportions of
+// the provider's size.
+func (pr provider) size(opts embedopt.Options) (int, int) {
+	width, height := pr.width, pr.height
+	if opts.Width > 0 {
+		if height > 0 && width > 0 && opts.Height == 0 {
+			height = opts.Width * height / width
+		}
+		width = opts.Width
+	}
+	if opts.Height > 0 {
+		height = opts.Height
+	}
+	return width, height
+}
+
+// writePlaceholder writes a placeholder holding content, which the theme's
+// embed script puts in its place when the reader clicks it.
+func writePlaceholder(w util.BufWriter, name, link string, content []byte, width, height int) {
+	host := link
+	if u, err := url.Parse(link); err == nil && u.Host != "" {
+		host = strings.TrimPrefix(u.Host, "www.")
+	}
+
+	_, _ = w.WriteString(`<span class="embed-placeholder" data-embed="`)
+	_, _ = w.Write(util.EscapeHTML(content))
+	_, _ = w.WriteString(`"`)
+	if width > 0 && height > 0 {
+		_, _ = w.WriteString(` style="width: `)
+		_, _ = w.WriteString(strconv.Itoa(width))
+		_, _ = w.WriteString(`px; aspect-ratio: `)
+		_, _ = w.WriteString(strconv.Itoa(width))
+		_, _ = w.WriteString(` / `)
+		_, _ = w.WriteString(strconv.Itoa(height))
+		_, _ = w.WriteString(`"`)
+	}
+	_, _ = w.WriteString(`><span class="embed-placeholder-text">This `)
+	_, _ = w.Write(util.EscapeHTML([]byte(name)))
+	_, _ = w.WriteString(` embed loads content from `)
+	_, _ = w.Write(util.EscapeHTML([]byte(host)))
+	_, _ = w.WriteString(`.</span><button type="button" class="embed-load">Load</button><a href="`)
+	_, _ = w.Write(util.EscapeHTML(util.URLEscape([]byte(link), true /* resolve references */)))
+	_, _ = w.WriteString(`" target="_blank" rel="noopener">Open on `)
+	_, _ = w.Write(util.EscapeHTML([]byte(host)))
+	_, _ = w.WriteString(`</a></span>`)
+}
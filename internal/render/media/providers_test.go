@@ -0,0 +1,168 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"geode/internal/config"
+	"geode/internal/render/embedopt"
+	"strings"
+	"testing"
+)
+
+func renderEmbed(t *testing.T, p *Providers, link string, opts embedopt.Options) (string, bool) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	ok := p.render(w, []byte(link), opts)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), ok
+}
+
+func TestDefaultProviders(t *testing.T) {
+	tests := []struct {
+		link string
+		want string // part of the embed, empty when no provider matches
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", `src="https://www.youtube.com/embed/dQw4w9WgXcQ" width="560" height="315"`},
+		{"https://youtube.com/watch?feature=share&v=dQw4w9WgXcQ", `src="https://www.youtube.com/embed/dQw4w9WgXcQ"`},
+		{"https://m.youtube.com/embed/dQw4w9WgXcQ", `src="https://www.youtube.com/embed/dQw4w9WgXcQ"`},
+		{"https://youtu.be/dQw4w9WgXcQ?t=42", `src="https://www.youtube.com/embed/dQw4w9WgXcQ"`},
+		{"https://www.youtube.com/shorts/abc_DEF-123", `src="https://www.youtube.com/embed/abc_DEF-123" width="315" height="560"`},
+		{"https://x.com/golang/status/1234567890", `<a href="https://twitter.com/golang/status/1234567890">@golang</a>`},
+		{"https://twitter.com/golang/status/1234567890", `class="twitter-tweet"`},
+		{"https://vimeo.com/76979871", `src="https://player.vimeo.com/video/76979871"`},
+		{"https://vimeo.com/video/76979871", `src="https://player.vimeo.com/video/76979871"`},
+		{"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", `src="https://open.spotify.com/embed/track/4uLU6hMCjMI75M1A2tKUQC" width="100%" height="352"`},
+		{"https://open.spotify.com/intl-de/album/1DFixLWuPkv3KT3TnV35m3", `src="https://open.spotify.com/embed/album/1DFixLWuPkv3KT3TnV35m3"`},
+		{"https://soundcloud.com/artist/a-track", `src="https://w.soundcloud.com/player/?url=https%3a%2f%2fsoundcloud.com%2fartist%2fa-track"`},
+		{"https://codepen.io/someone/pen/abcDEF", `src="https://codepen.io/someone/embed/abcDEF?default-tab=result"`},
+		{"https://gist.github.com/someone/0123abcd", `gist.github.com/someone/0123abcd.js`},
+		{"https://www.loom.com/share/0123abcd", `src="https://www.loom.com/embed/0123abcd"`},
+		{"https://www.youtube.com/channel/UC123", ""},
+		{"https://example.com/watch?v=dQw4w9WgXcQ", ""},
+		{"https://vimeo.com/channels/staffpicks", ""},
+		{"https://evil.test/?u=https://youtu.be/dQw4w9WgXcQ", ""},
+	}
+
+	for _, tt := range tests {
+		html, ok := renderEmbed(t, DefaultProviders(), tt.link, embedopt.Options{})
+		if ok != (tt.want != "") {
+			t.Errorf("%s: matched = %v, want %v", tt.link, ok, tt.want != "")
+			continue
+		}
+		if !strings.Contains(html, tt.want) {
+			t.Errorf("%s: embed lacks %s:\n%s", tt.link, tt.want, html)
+		}
+	}
+}
+
+func TestProviderOptions(t *testing.T) {
+	tests := []struct {
+		opts embedopt.Options
+		want []string
+	}{
+		{embedopt.Options{}, []string{`width="640" height="360"`, `title="Vimeo"`}},
+		{embedopt.Options{Alt: "A <talk>"}, []string{`title="A &lt;talk&gt;"`}},
+		{embedopt.Options{Width: 320}, []string{`width="320" height="180"`}},
+		{embedopt.Options{Width: 320, Height: 320}, []string{`width="320" height="320"`}},
+		{embedopt.Options{Height: 100}, []string{`width="640" height="100"`}},
+		{embedopt.Options{Caption: "The talk"}, []string{`<span class="embed-figure">`, `<span class="embed-caption">The talk</span></span>`}},
+	}
+
+	for _, tt := range tests {
+		html, ok := renderEmbed(t, DefaultProviders(), "https://vimeo.com/76979871", tt.opts)
+		if !ok {
+			t.Fatalf("%+v: no provider matched", tt.opts)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(html, want) {
+				t.Errorf("%+v: embed lacks %s:\n%s", tt.opts, want, html)
+			}
+		}
+	}
+}
+
+func TestNewProviders(t *testing.T) {
+	configured := []config.EmbedProvider{
+		{
+			Name:     "Internal",
+			Pattern:  `^https://video\.example\.com/v/(?P<id>\d+)`,
+			Template: `<iframe src="https://video.example.com/embed/{{.Group.id}}" width="{{.Width}}"></iframe>`,
+			Width:    800,
+		},
+		{
+			Name:     "youtube",
+			Pattern:  `^https://youtu\.be/(\w+)`,
+			Template: `<lite-youtube videoid="{{index .Match 1}}"></lite-youtube>`,
+		},
+		{Name: "Vimeo"},
+	}
+
+	p, err := NewProviders(configured, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		link, want string
+	}{
+		{"https://video.example.com/v/42", `<iframe src="https://video.example.com/embed/42" width="800"></iframe>`},
+		{"https://youtu.be/abc", `<lite-youtube videoid="abc"></lite-youtube>`},
+		{"https://www.youtube.com/watch?v=abc", ""},
+		{"https://vimeo.com/76979871", ""},
+		{"https://www.loom.com/share/0123abcd", `src="https://www.loom.com/embed/0123abcd"`},
+	}
+
+	for _, tt := range tests {
+		html, ok := renderEmbed(t, p, tt.link, embedopt.Options{})
+		if ok != (tt.want != "") || !strings.Contains(html, tt.want) {
+			t.Errorf("%s: embed = %q, %v, want %q", tt.link, html, ok, tt.want)
+		}
+	}
+
+	if configured[2].Pattern != "" || len(configured) != 3 {
+		t.Error("NewProviders changed the configured providers")
+	}
+}
+
+func TestNewProvidersErrors(t *testing.T) {
+	tests := []config.EmbedProvider{
+		{Name: "Bad pattern", Pattern: `(`, Template: `x`},
+		{Name: "Bad template", Pattern: `x`, Template: `{{.URL`},
+	}
+
+	for _, c := range tests {
+		if _, err := NewProviders([]config.EmbedProvider{c}, false); err == nil || !strings.Contains(err.Error(), c.Name) {
+			t.Errorf("%s: error = %v", c.Name, err)
+		}
+	}
+}
+
+func TestClickToLoad(t *testing.T) {
+	p, err := NewProviders(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html, ok := renderEmbed(t, p, "https://www.youtube.com/watch?v=abc&t=1", embedopt.Options{})
+	if !ok {
+		t.Fatal("no provider matched")
+	}
+
+	for _, want := range []string{
+		`<span class="embed-placeholder" data-embed="&lt;iframe src=`,
+		`style="width: 560px; aspect-ratio: 560 / 315"`,
+		`This YouTube embed loads content from youtube.com.`,
+		`<a href="https://www.youtube.com/watch?v=abc&amp;t=1" target="_blank" rel="noopener">Open on youtube.com</a>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("placeholder lacks %s:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "<iframe") {
+		t.Errorf("placeholder holds an unescaped iframe:\n%s", html)
+	}
+}
@@ -42,3 +42,13 @@ func (c *Collector) Tags() []string {
 	sort.Strings(out)
 	return out
 }
+
+func (c *Collector) Reset() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.seen = make(map[string]struct{})
+	c.mu.Unlock()
+}
@@ -11,6 +11,10 @@ type Node struct {
 	Target   []byte
 	Fragment []byte
 	Embed    bool
+	Labeled  bool // the link has a label after a pipe
+
+	// Content is the rendered note an embed includes, nil for anything else.
+	Content []byte
 }
 
 var _ ast.Node = (*Node)(nil)
@@ -24,20 +24,30 @@ func NewLinkCollector(resolver Resolver) *LinkCollector {
 }
 
 func (c *LinkCollector) CollectLink(n *Node, dest []byte, src []byte) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	title := string(n.Target)
-	if n.ChildCount() == 1 {
+	if n.Embed {
+		// The label of an embed holds its options, of which only the alt
+		// text names the target.
+		if alt := embedOptions(n, src).Alt; alt != "" {
+			title = alt
+		}
+	} else if n.ChildCount() == 1 {
 		labelBytes := nodeText(src, n.FirstChild())
 		if len(labelBytes) > 0 {
 			title = string(labelBytes)
 		}
 	}
 
+	c.Collect(title, string(dest))
+}
+
+func (c *LinkCollector) Collect(title, url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.links = append(c.links, CollectedLink{
 		Title: title,
-		URL:   string(dest),
+		URL:   url,
 	})
 }
 
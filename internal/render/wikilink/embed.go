@@ -0,0 +1,71 @@
+package wikilink
+
+import (
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// NoteEmbedder renders embedded notes on their own, so that nothing in them
+// leaks into the embedding note, and embedded files that are shown from their
+// contents. ok is false when n embeds neither.
+type NoteEmbedder interface {
+	EmbedNote(n *Node) (html []byte, ok bool)
+}
+
+var KindEmbedBlock = ast.NewNodeKind("WikiLinkEmbedBlock")
+
+// EmbedBlock replaces a paragraph that holds nothing but a note embed, since
+// the embedded content is rendered as a block of its own.
+type EmbedBlock struct {
+	ast.BaseBlock
+}
+
+func (n *EmbedBlock) Kind() ast.NodeKind {
+	return KindEmbedBlock
+}
+
+func (n *EmbedBlock) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, nil, nil)
+}
+
+type embedTransformer struct {
+	Embedder NoteEmbedder
+}
+
+func (t *embedTransformer) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	var embeds []*Node
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*Node); ok && entering && link.Embed {
+			embeds = append(embeds, link)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, n := range embeds {
+		html, ok := t.Embedder.EmbedNote(n)
+		if !ok {
+			continue
+		}
+		n.Content = html
+
+		para := n.Parent()
+		if para == nil || para.Kind() != ast.KindParagraph || para.ChildCount() != 1 {
+			continue
+		}
+
+		block := &EmbedBlock{}
+		para.Parent().ReplaceChild(para.Parent(), para, block)
+		block.AppendChild(block, n)
+	}
+}
+
+type embedBlockRenderer struct{}
+
+func (r *embedBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindEmbedBlock, func(util.BufWriter, []byte, ast.Node, bool) (ast.WalkStatus, error) {
+		return ast.WalkContinue, nil
+	})
+}
@@ -10,6 +10,10 @@ import (
 type Extender struct {
 	Resolver  Resolver
 	Collector *LinkCollector
+	Embedder  NoteEmbedder
+
+	// MarkPrivate marks links to unpublished notes as private.
+	MarkPrivate bool
 }
 
 func (e *Extender) Extend(md goldmark.Markdown) {
@@ -19,11 +23,33 @@ func (e *Extender) Extend(md goldmark.Markdown) {
 		),
 	)
 
+	if pr, ok := e.Resolver.(PathResolver); ok {
+		md.Parser().AddOptions(
+			parser.WithASTTransformers(
+				util.Prioritized(&LinkTransformer{Resolver: pr, Collector: e.Collector}, 199),
+			),
+		)
+	}
+
+	if e.Embedder != nil {
+		md.Parser().AddOptions(
+			parser.WithASTTransformers(
+				util.Prioritized(&embedTransformer{Embedder: e.Embedder}, 199),
+			),
+		)
+		md.Renderer().AddOptions(
+			renderer.WithNodeRenderers(
+				util.Prioritized(&embedBlockRenderer{}, 199),
+			),
+		)
+	}
+
 	md.Renderer().AddOptions(
 		renderer.WithNodeRenderers(
 			util.Prioritized(&Renderer{
-				Resolver:  e.Resolver,
-				Collector: e.Collector,
+				Resolver:    e.Resolver,
+				Collector:   e.Collector,
+				MarkPrivate: e.MarkPrivate,
 			}, 199),
 		),
 	)
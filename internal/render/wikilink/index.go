@@ -0,0 +1,172 @@
+package wikilink
+
+import (
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Index resolves link targets to files the way Obsidian does. Targets match
+// case-insensitively, "./" and "../" targets are relative to the linking
+// note, and a name shared by several files resolves to the one closest to the
+// linking note. Aliases only match targets that match no file.
+type Index struct {
+	keys    map[string][]string // lowercased path without .md -> files
+	names   map[string][]string // lowercased base name without .md -> files
+	aliases map[string][]string // lowercased alias -> files
+}
+
+// Match is the result of resolving a target. Candidates lists every file the
+// target could refer to when there is more than one.
+type Match struct {
+	Path       string
+	Candidates []string
+}
+
+func (m Match) Ambiguous() bool {
+	return len(m.Candidates) > 1
+}
+
+// NewIndex indexes files, given as slash separated paths relative to the
+// content root.
+func NewIndex(files []string) *Index {
+	x := &Index{
+		keys:    make(map[string][]string),
+		names:   make(map[string][]string),
+		aliases: make(map[string][]string),
+	}
+
+	for _, file := range files {
+		key := indexKey(file)
+		x.keys[key] = append(x.keys[key], file)
+
+		name := path.Base(key)
+		x.names[name] = append(x.names[name], file)
+	}
+
+	return x
+}
+
+// AddAlias makes file reachable by alias, for targets that name no file.
+func (x *Index) AddAlias(alias, file string) {
+	alias = strings.ToLower(strings.TrimSpace(alias))
+	if alias == "" || slices.Contains(x.aliases[alias], file) {
+		return
+	}
+	x.aliases[alias] = append(x.aliases[alias], file)
+}
+
+func indexKey(file string) string {
+	return strings.ToLower(strings.TrimSuffix(file, ".md"))
+}
+
+// Resolve finds the file target refers to when linked from the file at from.
+func (x *Index) Resolve(from, target string) Match {
+	target = strings.TrimSuffix(target, ".md\\")
+	target = strings.TrimSpace(strings.ReplaceAll(target, "\\", "/"))
+	if target == "" {
+		return Match{}
+	}
+
+	if strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../") {
+		rel := path.Join(path.Dir(from), target)
+		if strings.HasPrefix(rel, "../") {
+			return Match{}
+		}
+		return pick(from, x.keys[indexKey(rel)])
+	}
+
+	target = strings.Trim(target, "/")
+	key := indexKey(target)
+
+	if m := x.resolveFile(from, key); m.Path != "" {
+		return m
+	}
+	// Aliases are not paths, so they match as they are, even with a slash.
+	return pick(from, x.aliases[strings.ToLower(target)])
+}
+
+// resolveFile resolves key, a target that is not relative to the linking
+// note, to a file. A path from the content root wins. Otherwise a partial
+// path matches every file it is a suffix of, and a bare name every file with
+// that name.
+func (x *Index) resolveFile(from, key string) Match {
+	if files, ok := x.keys[key]; ok && strings.Contains(key, "/") {
+		return pick(from, files)
+	}
+
+	if !strings.Contains(key, "/") {
+		return pick(from, x.names[key])
+	}
+
+	var files []string
+	for _, file := range x.names[path.Base(key)] {
+		if strings.HasSuffix(indexKey(file), "/"+key) {
+			files = append(files, file)
+		}
+	}
+
+	return pick(from, files)
+}
+
+// ResolvePath finds the file at target, a path relative to the folder of from
+// or to the content root. Unlike Resolve, it never falls back to a name.
+func (x *Index) ResolvePath(from, target string) Match {
+	target = strings.ReplaceAll(target, "\\", "/")
+
+	if !strings.HasPrefix(target, "/") {
+		rel := path.Join(path.Dir(from), target)
+		if files, ok := x.keys[indexKey(rel)]; ok {
+			return pick(from, files)
+		}
+	}
+
+	root := strings.TrimPrefix(path.Clean("/"+target), "/")
+	return pick(from, x.keys[indexKey(root)])
+}
+
+// pick chooses the file closest to from, then the one with the shortest path.
+func pick(from string, files []string) Match {
+	switch len(files) {
+	case 0:
+		return Match{}
+	case 1:
+		return Match{Path: files[0]}
+	}
+
+	candidates := append([]string(nil), files...)
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if da, db := distance(from, a), distance(from, b); da != db {
+			return da < db
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+
+	return Match{Path: candidates[0], Candidates: candidates}
+}
+
+// distance counts the folders between the folders of two files.
+func distance(from, to string) int {
+	a := splitDir(from)
+	b := splitDir(to)
+
+	common := 0
+	for common < len(a) && common < len(b) && a[common] == b[common] {
+		common++
+	}
+
+	return len(a) - common + len(b) - common
+}
+
+func splitDir(file string) []string {
+	dir := path.Dir(file)
+	if dir == "." {
+		return nil
+	}
+	return strings.Split(dir, "/")
+}
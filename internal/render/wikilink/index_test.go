@@ -0,0 +1,95 @@
+package wikilink
+
+import (
+	"slices"
+	"testing"
+)
+
+func testIndex() *Index {
+	x := NewIndex([]string{
+		"Note.md",
+		"a/Note.md",
+		"b/Note.md",
+		"b/c/Deep.md",
+		"Projects/Plan.md",
+		"img/cat.png",
+		"a/cat.png",
+	})
+	x.AddAlias("The Plan", "Projects/Plan.md")
+	x.AddAlias("Work/Plan", "Projects/Plan.md")
+	// Aliases never shadow files.
+	x.AddAlias("Note", "Projects/Plan.md")
+	x.AddAlias("a/Note", "Projects/Plan.md")
+	return x
+}
+
+func TestIndexResolve(t *testing.T) {
+	x := testIndex()
+
+	tests := []struct {
+		name       string
+		from       string
+		target     string
+		want       string
+		candidates []string
+	}{
+		{name: "bare name picks the closest", from: "b/Other.md", target: "Note", want: "b/Note.md",
+			candidates: []string{"b/Note.md", "Note.md", "a/Note.md"}},
+		{name: "bare name from the root", from: "Index.md", target: "Note", want: "Note.md",
+			candidates: []string{"Note.md", "a/Note.md", "b/Note.md"}},
+		{name: "case-insensitive", from: "Index.md", target: "projects/plan", want: "Projects/Plan.md"},
+		{name: "extension", from: "Index.md", target: "Deep.md", want: "b/c/Deep.md"},
+		{name: "path from the root", from: "b/Other.md", target: "a/Note", want: "a/Note.md"},
+		{name: "partial path", from: "Index.md", target: "c/Deep", want: "b/c/Deep.md"},
+		{name: "leading slash", from: "Index.md", target: "/a/Note", want: "a/Note.md"},
+		{name: "relative", from: "b/c/Deep.md", target: "../Note", want: "b/Note.md"},
+		{name: "relative outside the root", from: "Index.md", target: "../Note", want: ""},
+		{name: "alias", from: "Index.md", target: "the plan", want: "Projects/Plan.md"},
+		{name: "alias with a slash", from: "Index.md", target: "Work/Plan", want: "Projects/Plan.md"},
+		{name: "file name over alias", from: "Projects/Plan.md", target: "Note", want: "Note.md",
+			candidates: []string{"Note.md", "a/Note.md", "b/Note.md"}},
+		{name: "file path over alias", from: "Projects/Plan.md", target: "a/Note", want: "a/Note.md"},
+		{name: "asset", from: "a/Note.md", target: "cat.png", want: "a/cat.png",
+			candidates: []string{"a/cat.png", "img/cat.png"}},
+		{name: "missing path does not fall back to the name", from: "Index.md", target: "missing/Note", want: ""},
+		{name: "missing name", from: "Index.md", target: "Nope", want: ""},
+		{name: "empty", from: "Index.md", target: " ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := x.Resolve(tt.from, tt.target)
+			if m.Path != tt.want {
+				t.Errorf("Resolve(%q, %q) = %q, want %q", tt.from, tt.target, m.Path, tt.want)
+			}
+			if tt.candidates != nil && !slices.Equal(m.Candidates, tt.candidates) {
+				t.Errorf("Resolve(%q, %q) candidates = %q, want %q", tt.from, tt.target, m.Candidates, tt.candidates)
+			}
+			if m.Ambiguous() != (len(tt.candidates) > 1) {
+				t.Errorf("Resolve(%q, %q) ambiguous = %v", tt.from, tt.target, m.Ambiguous())
+			}
+		})
+	}
+}
+
+func TestIndexResolvePath(t *testing.T) {
+	x := testIndex()
+
+	tests := []struct {
+		from, target, want string
+	}{
+		{"a/Note.md", "cat.png", "a/cat.png"},
+		{"a/Note.md", "../img/cat.png", "img/cat.png"},
+		{"a/Note.md", "/img/cat.png", "img/cat.png"},
+		{"b/Note.md", "img/cat.png", "img/cat.png"},
+		{"b/Note.md", "cat.png", ""},
+		{"Index.md", "../../img/cat.png", "img/cat.png"},
+		{"Index.md", "Projects/Plan.md", "Projects/Plan.md"},
+	}
+
+	for _, tt := range tests {
+		if got := x.ResolvePath(tt.from, tt.target).Path; got != tt.want {
+			t.Errorf("ResolvePath(%q, %q) = %q, want %q", tt.from, tt.target, got, tt.want)
+		}
+	}
+}
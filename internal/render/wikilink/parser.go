@@ -47,14 +47,15 @@ func (p *Parser) Parse(_ ast.Node, block text.Reader, _ parser.Context) ast.Node
 	if idx := bytes.Index(n.Target, _pipe); idx >= 0 {
 		n.Target = n.Target[:idx]                // [[ ... |
 		seg = seg.WithStart(seg.Start + idx + 1) // | ... ]]
+		n.Labeled = true
 	}
 
 	if len(n.Target) == 0 || seg.Len() == 0 {
 		return nil
 	}
 
-	if idx := bytes.LastIndex(n.Target, _hash); idx >= 0 {
-		n.Fragment = n.Target[idx+1:] // Foo#Bar => Bar
+	if idx := bytes.Index(n.Target, _hash); idx >= 0 {
+		n.Fragment = n.Target[idx+1:] // Foo#Bar#Baz => Bar#Baz
 		n.Target = n.Target[:idx]     // Foo#Bar => Foo
 	}
 
@@ -3,9 +3,10 @@ package wikilink
 import (
 	"bytes"
 	"fmt"
+	"geode/internal/render/embedopt"
 	"io"
+	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 
@@ -17,7 +18,12 @@ import (
 type Renderer struct {
 	Resolver  Resolver
 	Collector *LinkCollector
-	hasDest   sync.Map
+
+	// MarkPrivate marks links to unpublished notes as private instead of
+	// rendering their label as plain text.
+	MarkPrivate bool
+
+	closing sync.Map // node -> closing tag
 }
 
 func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
@@ -39,11 +45,17 @@ func (r *Renderer) Render(w util.BufWriter, src []byte, node ast.Node, entering
 }
 
 func (r *Renderer) enter(w util.BufWriter, n *Node, src []byte) (ast.WalkStatus, error) {
+	if n.Content != nil {
+		_, _ = w.Write(n.Content)
+		return ast.WalkSkipChildren, nil
+	}
+
 	dest, err := r.Resolver.ResolveWikilink(n)
 	if err != nil {
 		return ast.WalkStop, fmt.Errorf("resolve %q: %w", n.Target, err)
 	}
 	if len(dest) == 0 {
+		r.unresolved(w, n)
 		return ast.WalkContinue, nil
 	}
 
@@ -51,62 +63,141 @@ func (r *Renderer) enter(w util.BufWriter, n *Node, src []byte) (ast.WalkStatus,
 		r.Collector.CollectLink(n, dest, src)
 	}
 
-	img := resolveAsImage(n)
-	if !img {
-		r.hasDest.Store(n, struct{}{})
+	switch embedKind(n) {
+	case embedImage:
+	case embedAudio, embedVideo, embedPDF:
+		r.renderMedia(w, n, dest, src)
+		return ast.WalkSkipChildren, nil
+	default:
+		r.closing.Store(n, "</a>")
 		_, _ = w.WriteString(`<a href="`)
 		_, _ = w.Write(util.URLEscape(dest, true /* resolve references */))
 		_, _ = w.WriteString(`">`)
 		return ast.WalkContinue, nil
 	}
 
+	opts := embedOptions(n, src)
+	opts.OpenFigure(w)
+
 	_, _ = w.WriteString(`<img src="`)
 	_, _ = w.Write(util.URLEscape(dest, true /* resolve references */))
 	_, _ = w.WriteString(`"`)
+	opts.WriteAlt(w, path.Base(string(n.Target)))
+	opts.WriteSize(w)
+	_, _ = w.WriteString(`>`)
 
-	_, _ = w.WriteString(` alt="`)
-	_, _ = w.Write(util.EscapeHTML(dest))
-	_, _ = w.WriteString(`"`)
+	opts.CloseFigure(w)
+	return ast.WalkSkipChildren, nil
+}
 
-	if n.ChildCount() == 1 {
-		label := nodeText(src, n.FirstChild())
+// embedOptions reads the alt text, caption and size of an embed from its
+// label, as in ![[image.png|alt|300x200]].
+func embedOptions(n *Node, src []byte) embedopt.Options {
+	if !n.Labeled || n.ChildCount() != 1 {
+		return embedopt.Options{}
+	}
+	return embedopt.Parse(string(nodeText(src, n.FirstChild())))
+}
 
-		if width, ok := parseWidth(label); ok {
-			_, _ = w.WriteString(` width="`)
-			_, _ = w.WriteString(strconv.Itoa(width))
-			_, _ = w.WriteString(`"`)
-		}
+// renderMedia embeds an audio or video player, or a PDF viewer. Fragments
+// such as #t=10,20 or #page=3 are part of dest, where browsers honor them.
+func (r *Renderer) renderMedia(w util.BufWriter, n *Node, dest, src []byte) {
+	url := util.URLEscape(dest, true /* resolve references */)
+	name := util.EscapeHTML(n.Target)
+	opts := embedOptions(n, src)
+
+	opts.OpenFigure(w)
+	defer opts.CloseFigure(w)
+
+	switch embedKind(n) {
+	case embedAudio:
+		_, _ = w.WriteString(`<audio src="`)
+		_, _ = w.Write(url)
+		_, _ = w.WriteString(`" controls preload="metadata">`)
+		writeFallbackLink(w, url, name)
+		_, _ = w.WriteString(`</audio>`)
+
+	case embedVideo:
+		_, _ = w.WriteString(`<video src="`)
+		_, _ = w.Write(url)
+		_, _ = w.WriteString(`" controls preload="metadata"`)
+		opts.WriteSize(w)
+		_, _ = w.WriteString(`>`)
+		writeFallbackLink(w, url, name)
+		_, _ = w.WriteString(`</video>`)
+
+	case embedPDF:
+		_, _ = w.WriteString(`<iframe class="pdf-embed" src="`)
+		_, _ = w.Write(url)
+		_, _ = w.WriteString(`" title="`)
+		_, _ = w.Write(name)
+		_, _ = w.WriteString(`"`)
+		opts.WriteSize(w)
+		_, _ = w.WriteString(`></iframe>`)
 	}
+}
 
-	_, _ = w.WriteString(`>`)
-	return ast.WalkSkipChildren, nil
+func writeFallbackLink(w util.BufWriter, url, name []byte) {
+	_, _ = w.WriteString(`<a href="`)
+	_, _ = w.Write(url)
+	_, _ = w.WriteString(`">`)
+	_, _ = w.Write(name)
+	_, _ = w.WriteString(`</a>`)
 }
 
-func parseWidth(label []byte) (int, bool) {
-	w, err := strconv.Atoi(strings.TrimSpace(string(label)))
-	if err != nil || w <= 0 {
-		return 0, false
+// unresolved opens the element around the label of a link that points at
+// no published file. Links to unpublished notes keep their target private.
+func (r *Renderer) unresolved(w util.BufWriter, n *Node) {
+	if len(n.Target) == 0 {
+		return
 	}
-	return w, true
+
+	if ur, ok := r.Resolver.(UnpublishedResolver); ok && ur.IsUnpublished(n) {
+		if r.MarkPrivate {
+			_, _ = w.WriteString(`<span class="private-link" title="Private note">`)
+			r.closing.Store(n, "</span>")
+		}
+		return
+	}
+
+	_, _ = w.WriteString(`<span class="unresolved-link" data-target="`)
+	_, _ = w.Write(util.EscapeHTML(n.Target))
+	_, _ = w.WriteString(`">`)
+	r.closing.Store(n, "</span>")
 }
 
 func (r *Renderer) exit(w util.BufWriter, n *Node) {
-	if _, ok := r.hasDest.LoadAndDelete(n); ok {
-		_, _ = w.WriteString("</a>")
+	if tag, ok := r.closing.LoadAndDelete(n); ok {
+		_, _ = w.WriteString(tag.(string))
 	}
 }
 
-func resolveAsImage(n *Node) bool {
+const (
+	embedNone = iota
+	embedImage
+	embedAudio
+	embedVideo
+	embedPDF
+)
+
+// embedKind tells how an embed is shown from the extension of its target.
+// Anything else is linked to.
+func embedKind(n *Node) int {
 	if !n.Embed {
-		return false
+		return embedNone
 	}
 
-	filename := string(n.Target)
-	switch ext := filepath.Ext(filename); ext {
+	switch strings.ToLower(filepath.Ext(string(n.Target))) {
 	case ".apng", ".avif", ".gif", ".jpg", ".jpeg", ".jfif", ".pjpeg", ".pjp", ".png", ".svg", ".webp":
-		return true
+		return embedImage
+	case ".mp3", ".wav", ".ogg", ".m4a", ".flac":
+		return embedAudio
+	case ".mp4", ".mov", ".webm", ".ogv":
+		return embedVideo
+	case ".pdf":
+		return embedPDF
 	default:
-		return false
+		return embedNone
 	}
 }
 
@@ -1,67 +1,216 @@
 package wikilink
 
 import (
-	"path/filepath"
+	"fmt"
+	"geode/internal/render/blockref"
+	"geode/internal/render/headingid"
+	"net/url"
+	"path"
 	"strings"
-	"unicode"
 )
 
 type Resolver interface {
 	ResolveWikilink(*Node) (destination []byte, err error)
 }
 
+// PathResolver is implemented by resolvers that also resolve the destination
+// of regular Markdown links and images pointing at notes and files, relative
+// to the note. ok is false for destinations that should be left alone.
+type PathResolver interface {
+	ResolvePath(dest []byte) (destination []byte, ok bool)
+}
+
+// UnpublishedResolver is implemented by resolvers that know the notes left
+// out of the site, so that links to them can be told from broken ones.
+type UnpublishedResolver interface {
+	IsUnpublished(*Node) bool
+}
+
+// Unresolved is a wikilink that points at no published file.
+type Unresolved struct {
+	Target      string
+	Unpublished bool   // the target is a note left out of the site
+	Embedded    string // the embedded note the link is in, empty for the note itself
+}
+
+// Lookup is a link target resolved for a note, relative to the note at From.
+// Path lookups resolve the destination of a Markdown link, the others a
+// wikilink target.
+type Lookup struct {
+	From   string
+	Target string
+	Path   bool
+}
+
+// PageResolver resolves the wikilinks of one note at a time to page URLs.
+// Ambiguous links are resolved to the closest candidate and recorded as
+// warnings for the note, links that resolve to nothing are recorded as
+// unresolved.
 type PageResolver struct {
-	Pages         map[string]string
-	ShortestPaths map[string]string
+	Index       *Index
+	URLs        map[string]string              // file -> page URL
+	Headings    map[string][]headingid.Heading // file -> headings of the note
+	Unpublished *Index                         // notes left out of the site, may be nil
+
+	from       string
+	warnings   []string
+	embedded   []string // warnings about embedded notes
+	unresolved []Unresolved
+	lookups    []Lookup
+}
+
+// Reset prepares the resolver for the note at from, relative to the content
+// root.
+func (r *PageResolver) Reset(from string) {
+	r.from = from
+	r.warnings = r.warnings[:0]
+	r.embedded = r.embedded[:0]
+	r.unresolved = r.unresolved[:0]
+	r.lookups = r.lookups[:0]
+}
+
+func (r *PageResolver) Warnings() []string {
+	return append(append([]string(nil), r.warnings...), r.embedded...)
 }
 
-func (r PageResolver) ResolveWikilink(n *Node) ([]byte, error) {
+// Warn records a warning about the note being resolved.
+func (r *PageResolver) Warn(warning string) {
+	r.warnings = append(r.warnings, warning)
+}
+
+func (r *PageResolver) Unresolved() []Unresolved {
+	return append([]Unresolved(nil), r.unresolved...)
+}
+
+// Adopt takes over what child recorded while resolving a note embedded in
+// the one r resolves, attributed to the embedded note, so that problems in
+// it are reported for the page it shows up on.
+func (r *PageResolver) Adopt(child *PageResolver) {
+	for _, warning := range child.warnings {
+		r.embedded = append(r.embedded, fmt.Sprintf("embedded %s: %s", child.from, warning))
+	}
+	r.embedded = append(r.embedded, child.embedded...)
+
+	for _, u := range child.unresolved {
+		if u.Embedded == "" {
+			u.Embedded = child.from
+		}
+		r.unresolved = append(r.unresolved, u)
+	}
+
+	r.Record(child.lookups...)
+}
+
+// Lookups returns every target resolved since the last Reset, so that a
+// rendered note can be reused for as long as they resolve the same way.
+func (r *PageResolver) Lookups() []Lookup {
+	return append([]Lookup(nil), r.lookups...)
+}
+
+// Record adds lookups made on behalf of the note being resolved, such as
+// those of the notes it embeds.
+func (r *PageResolver) Record(lookups ...Lookup) {
+	r.lookups = append(r.lookups, lookups...)
+}
+
+func (r *PageResolver) IsUnpublished(n *Node) bool {
+	r.Record(Lookup{From: r.from, Target: string(n.Target)})
+	return r.Unpublished != nil && len(n.Target) > 0 && r.Unpublished.Resolve(r.from, string(n.Target)).Path != ""
+}
+
+func (r *PageResolver) ResolveWikilink(n *Node) ([]byte, error) {
 	if len(n.Target) == 0 {
 		return nil, nil
 	}
 
-	target := string(n.Target)
-	target = strings.TrimSuffix(target, ".md\\")
-	target = strings.Trim(target, "/")
-	target = filepath.ToSlash(target)
+	r.Record(Lookup{From: r.from, Target: string(n.Target)})
+	m := r.Index.Resolve(r.from, string(n.Target))
+	if m.Path == "" {
+		r.unresolved = append(r.unresolved, Unresolved{Target: string(n.Target), Unpublished: r.IsUnpublished(n)})
+		return nil, nil
+	}
+	if m.Ambiguous() {
+		r.warnings = append(r.warnings, AmbiguousWarning(string(n.Target), m))
+	}
+
+	return r.URL(m.Path, string(n.Fragment)), nil
+}
 
-	// Absolute Path
-	if dest, ok := r.Pages[target]; ok {
-		return withFragment(dest, n), nil
+func (r *PageResolver) ResolvePath(dest []byte) ([]byte, bool) {
+	target, fragment, ok := SplitDestination(string(dest))
+	if !ok {
+		return nil, false
 	}
 
-	// Shortest Path
-	base := filepath.Base(target)
-	if dest, ok := r.ShortestPaths[base]; ok {
-		return withFragment(dest, n), nil
+	r.Record(Lookup{From: r.from, Target: target, Path: true})
+	m := r.Index.ResolvePath(r.from, target)
+	if m.Path == "" {
+		// Links to missing notes are left alone, anything else with an
+		// extension is a file that was never copied.
+		if ext := path.Ext(target); ext != "" && ext != ".md" {
+			r.warnings = append(r.warnings, fmt.Sprintf("missing asset %q", target))
+		}
+		return nil, false
 	}
 
-	return nil, nil
+	return r.URL(m.Path, fragment), true
 }
 
-func withFragment(dest string, n *Node) []byte {
-	if len(n.Fragment) > 0 {
-		dest += "#" + transformHeadingID(string(n.Fragment))
+// SplitDestination splits the destination of a Markdown link into the
+// unescaped path and fragment it points at. ok is false for destinations that
+// are not relative paths: same-page anchors, and URLs with a scheme or host.
+func SplitDestination(dest string) (target, fragment string, ok bool) {
+	if dest == "" || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "//") {
+		return "", "", false
 	}
-	return []byte(dest)
+	if u, err := url.Parse(dest); err != nil || u.Scheme != "" {
+		return "", "", false
+	}
+
+	target, fragment, _ = strings.Cut(dest, "#")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		fragment = unescaped
+	}
+	return target, fragment, true
 }
 
-func transformHeadingID(text string) string {
-	text = strings.TrimSpace(text)
-	text = strings.TrimLeft(text, "#")
-	text = strings.TrimSpace(text)
+// AmbiguousWarning describes a link that matched several files.
+func AmbiguousWarning(target string, m Match) string {
+	return fmt.Sprintf("ambiguous link %q matches %s, using %s", target, strings.Join(m.Candidates, ", "), m.Path)
+}
 
-	var result strings.Builder
+// URL returns the page URL of file with the anchor a link fragment refers
+// to: a block, or one of the note's headings matched the way Obsidian does.
+// Fragments naming no heading fall back to the id such a heading would get.
+func (r *PageResolver) URL(file, fragment string) []byte {
+	dest := r.URLs[file]
 
-	for _, r := range text {
-		if unicode.IsLetter(r) || unicode.IsDigit(r) {
-			result.WriteRune(unicode.ToLower(r))
-		} else if unicode.IsSpace(r) || r == '-' || r == '_' {
-			result.WriteRune('-')
+	fragment = strings.TrimSpace(fragment)
+	// Fragments of other files, like #page=3 or #t=10, are for the browser.
+	if path.Ext(file) != ".md" {
+		if fragment != "" {
+			dest += "#" + fragment
 		}
+		return []byte(dest)
+	}
+
+	if id, ok := strings.CutPrefix(fragment, "^"); ok && blockref.Valid(id) {
+		return []byte(dest + "#" + blockref.ID(id))
+	}
+	if fragment == "" {
+		return []byte(dest)
+	}
+
+	if h, ok := headingid.Match(r.Headings[file], fragment); ok {
+		return []byte(dest + "#" + h.ID)
 	}
 
-	id := result.String()
-	id = strings.Trim(id, "-")
-	return id
+	parts := strings.Split(fragment, "#")
+	if id := headingid.Slug(parts[len(parts)-1]); id != "" {
+		dest += "#" + id
+	}
+	return []byte(dest)
 }
@@ -0,0 +1,132 @@
+package wikilink
+
+import (
+	"bytes"
+	"geode/internal/render/headingid"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+)
+
+func testResolver() *PageResolver {
+	return &PageResolver{
+		Index: testIndex(),
+		URLs: map[string]string{
+			"Note.md":          "/note",
+			"a/Note.md":        "/a/note",
+			"b/Note.md":        "/b/note",
+			"b/c/Deep.md":      "/b/c/deep",
+			"Projects/Plan.md": "/projects/plan",
+			"img/cat.png":      "/img/cat.png",
+			"a/cat.png":        "/a/cat.png",
+		},
+		Headings: map[string][]headingid.Heading{
+			"Projects/Plan.md": headingid.Parse([]byte("# Goals\n\n## Next Steps\n")),
+		},
+	}
+}
+
+func TestSplitDestination(t *testing.T) {
+	tests := []struct {
+		dest, target, fragment string
+		ok                     bool
+	}{
+		{"Note.md", "Note.md", "", true},
+		{"../a/Note.md#Some%20Heading", "../a/Note.md", "Some Heading", true},
+		{"My%20Note.md", "My Note.md", "", true},
+		{"/img/cat.png", "/img/cat.png", "", true},
+		{"#local", "", "", false},
+		{"", "", "", false},
+		{"https://example.com/Note.md", "", "", false},
+		{"mailto:someone@example.com", "", "", false},
+		{"//example.com/Note.md", "", "", false},
+	}
+
+	for _, tt := range tests {
+		target, fragment, ok := SplitDestination(tt.dest)
+		if target != tt.target || fragment != tt.fragment || ok != tt.ok {
+			t.Errorf("SplitDestination(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.dest, target, fragment, ok, tt.target, tt.fragment, tt.ok)
+		}
+	}
+}
+
+func TestPageResolverResolvePath(t *testing.T) {
+	tests := []struct {
+		from, dest, want string
+		ok               bool
+		warning          string
+	}{
+		{"a/Note.md", "cat.png", "/a/cat.png", true, ""},
+		{"a/Note.md", "../img/cat.png", "/img/cat.png", true, ""},
+		{"a/Note.md", "../Projects/Plan.md#next%20steps", "/projects/plan#next-steps", true, ""},
+		{"Index.md", "Projects/Plan.md#Goals#Next Steps", "/projects/plan#next-steps", true, ""},
+		{"Index.md", "Projects/Plan.md#Elsewhere", "/projects/plan#elsewhere", true, ""},
+		{"Index.md", "b/Note.md#^block-1", "/b/note#^block-1", true, ""},
+		{"Index.md", "img/cat.png#frag", "/img/cat.png#frag", true, ""},
+		{"Index.md", "Missing.md", "", false, ""},
+		{"Index.md", "missing.pdf", "", false, `missing asset "missing.pdf"`},
+		{"Index.md", "https://example.com/cat.png", "", false, ""},
+	}
+
+	for _, tt := range tests {
+		r := testResolver()
+		r.Reset(tt.from)
+
+		dest, ok := r.ResolvePath([]byte(tt.dest))
+		if string(dest) != tt.want || ok != tt.ok {
+			t.Errorf("ResolvePath(%q) from %s = %q, %v, want %q, %v", tt.dest, tt.from, dest, ok, tt.want, tt.ok)
+		}
+
+		warnings := r.Warnings()
+		if tt.warning == "" && len(warnings) > 0 || tt.warning != "" && !slices.Equal(warnings, []string{tt.warning}) {
+			t.Errorf("ResolvePath(%q) warnings = %q, want %q", tt.dest, warnings, tt.warning)
+		}
+	}
+}
+
+func TestLinkTransformer(t *testing.T) {
+	r := testResolver()
+	r.Reset("a/Note.md")
+	c := NewLinkCollector(r)
+
+	md := goldmark.New(goldmark.WithExtensions(&Extender{Resolver: r, Collector: c}))
+
+	src := strings.Join([]string{
+		"[the plan](../Projects/Plan.md#Goals)",
+		"[](../b/c/Deep.md)",
+		"[elsewhere](https://example.com/)",
+		"![a cat](cat.png)",
+		"![[Plan|A plan|The caption|300]]",
+		"![[cat.png|300]]",
+	}, "\n\n")
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+
+	for _, want := range []string{
+		`<a href="/projects/plan#goals">the plan</a>`,
+		`<a href="/b/c/deep"></a>`,
+		`<a href="https://example.com/">elsewhere</a>`,
+		`src="/a/cat.png"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("output lacks %s:\n%s", want, html)
+		}
+	}
+
+	want := []CollectedLink{
+		{Title: "the plan", URL: "/projects/plan#goals"},
+		{Title: "/b/c/deep", URL: "/b/c/deep"},
+		{Title: "A plan", URL: "/projects/plan"},
+		{Title: "cat.png", URL: "/a/cat.png"},
+	}
+	if got := c.GetLinks(); !slices.Equal(got, want) {
+		t.Errorf("collected %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package wikilink
+
+import (
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// LinkTransformer rewrites regular Markdown links and images that point at
+// notes or files to their URLs. Links are collected like wikilinks.
+type LinkTransformer struct {
+	Resolver  PathResolver
+	Collector *LinkCollector
+}
+
+func (t *LinkTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	src := reader.Source()
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if img, ok := n.(*ast.Image); ok {
+			if dest, ok := t.Resolver.ResolvePath(img.Destination); ok {
+				img.Destination = dest
+			}
+			return ast.WalkContinue, nil
+		}
+
+		link, ok := n.(*ast.Link)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		dest, ok := t.Resolver.ResolvePath(link.Destination)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		link.Destination = dest
+
+		if t.Collector != nil {
+			title := string(nodeText(src, link))
+			if title == "" {
+				title = string(dest)
+			}
+			t.Collector.Collect(title, string(dest))
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
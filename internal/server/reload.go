@@ -0,0 +1,229 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"geode/internal/content"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const keepaliveInterval = 15 * time.Second
+
+// ReloadHub pushes live reload events to the browsers connected to /_reload.
+// Every client reports the page it shows, so a rebuild only reloads the
+// clients whose page actually changed. A failed rebuild is reported to every
+// client until the next successful one.
+type ReloadHub struct {
+	mu      sync.Mutex
+	clients map[*reloadClient]struct{}
+	lastErr *reloadEvent
+}
+
+// reloadClient holds the latest state a client has not been sent yet rather
+// than a queue of events, so a slow client skips straight to it and never
+// misses the last one.
+type reloadClient struct {
+	page string
+	wake chan struct{} // signalled when something is pending
+
+	mu      sync.Mutex
+	pending *reloadEvent // the latest reload, build-error or clear
+	css     bool         // stylesheets changed
+}
+
+// push records event as the latest state. A reload replaces whatever is
+// pending, and also clears an error, so a clear never replaces a reload.
+func (c *reloadClient) push(event reloadEvent) {
+	c.mu.Lock()
+	switch {
+	case event.name == "css":
+		c.css = true
+	case event.name == "clear" && c.pending != nil && c.pending.name == "reload":
+		// The reload clears the error already.
+	default:
+		c.pending = &event
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pending events in the order to send them, and resets the
+// client. A stylesheet swap is left out when the page reloads anyway.
+func (c *reloadClient) take() []reloadEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var events []reloadEvent
+	if c.css && (c.pending == nil || c.pending.name != "reload") {
+		events = append(events, reloadEvent{"css", "css"})
+	}
+	if c.pending != nil {
+		events = append(events, *c.pending)
+	}
+
+	c.pending, c.css = nil, false
+	return events
+}
+
+type reloadEvent struct {
+	name string
+	data string
+}
+
+// BuildError is the payload of a "build-error" event.
+type BuildError struct {
+	Path    string `json:"path,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func NewReloadHub() *ReloadHub {
+	return &ReloadHub{clients: make(map[*reloadClient]struct{})}
+}
+
+func (h *ReloadHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	c := &reloadClient{
+		page: normalizePageURL(r.URL.Query().Get("page")),
+		wake: make(chan struct{}, 1),
+	}
+
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	if h.lastErr != nil {
+		c.push(*h.lastErr)
+	}
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+	}()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-c.wake:
+			for _, event := range c.take() {
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, event.data)
+			}
+			flusher.Flush()
+		case <-keepalive.C:
+			// Comment lines are ignored by EventSource but keep proxies
+			// from closing an idle stream.
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// ReloadAll reloads every connected page.
+func (h *ReloadHub) ReloadAll() {
+	h.send(reloadEvent{"reload", "reload"}, all)
+}
+
+// ReloadPages reloads the clients that show one of the given page URLs.
+func (h *ReloadHub) ReloadPages(urls []string) {
+	pages := make(map[string]struct{}, len(urls))
+	for _, u := range urls {
+		pages[normalizePageURL(u)] = struct{}{}
+	}
+
+	h.send(reloadEvent{"reload", "reload"}, func(c *reloadClient) bool {
+		_, ok := pages[c.page]
+		return ok
+	})
+}
+
+// ReloadCSS makes every client swap its stylesheets in place.
+func (h *ReloadHub) ReloadCSS() {
+	h.send(reloadEvent{"css", "css"}, all)
+}
+
+// ReportError shows err in every connected page, and in pages that connect
+// before the error is cleared.
+func (h *ReloadHub) ReportError(err error) {
+	payload := BuildError{Message: err.Error()}
+
+	var noteErr *content.NoteError
+	if errors.As(err, &noteErr) {
+		payload = BuildError{Path: noteErr.Path, Line: noteErr.Line, Message: noteErr.Err.Error()}
+	}
+
+	data, jsonErr := json.Marshal(payload)
+	if jsonErr != nil {
+		return
+	}
+
+	event := reloadEvent{"build-error", string(data)}
+
+	h.mu.Lock()
+	h.lastErr = &event
+	h.mu.Unlock()
+
+	h.send(event, all)
+}
+
+// ClearError removes a reported error from every page that still shows it.
+func (h *ReloadHub) ClearError() {
+	h.mu.Lock()
+	hadErr := h.lastErr != nil
+	h.lastErr = nil
+	h.mu.Unlock()
+
+	if hadErr {
+		h.send(reloadEvent{"clear", "clear"}, all)
+	}
+}
+
+// send never blocks on a slow client: the event replaces whatever the client
+// has not been sent yet.
+func (h *ReloadHub) send(event reloadEvent, match func(*reloadClient) bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		if !match(c) {
+			continue
+		}
+
+		c.push(event)
+	}
+}
+
+func all(*reloadClient) bool { return true }
+
+func normalizePageURL(u string) string {
+	u = strings.TrimSuffix(u, ".html")
+	u = strings.TrimSuffix(u, "/")
+	u = strings.TrimSuffix(u, "/index")
+	if u == "" || u == "index" {
+		return "/"
+	}
+	if !strings.HasPrefix(u, "/") {
+		u = "/" + u
+	}
+	return u
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestReloadCoalesces(t *testing.T) {
+	tests := []struct {
+		name  string
+		sends func(h *ReloadHub)
+		want  []string
+	}{
+		{
+			name:  "reload",
+			sends: func(h *ReloadHub) { h.ReloadPages([]string{"/a.html"}) },
+			want:  []string{"reload"},
+		},
+		{
+			name:  "other page",
+			sends: func(h *ReloadHub) { h.ReloadPages([]string{"/b"}) },
+			want:  nil,
+		},
+		{
+			name: "many reloads",
+			sends: func(h *ReloadHub) {
+				for range 10 {
+					h.ReloadAll()
+				}
+			},
+			want: []string{"reload"},
+		},
+		{
+			name: "error after reload",
+			sends: func(h *ReloadHub) {
+				h.ReloadAll()
+				h.ReportError(errors.New("broken"))
+			},
+			want: []string{"build-error"},
+		},
+		{
+			name: "error cleared",
+			sends: func(h *ReloadHub) {
+				h.ReportError(errors.New("broken"))
+				h.ClearError()
+			},
+			want: []string{"clear"},
+		},
+		{
+			name: "error fixed",
+			sends: func(h *ReloadHub) {
+				h.ReportError(errors.New("broken"))
+				h.ReloadAll()
+				h.ClearError()
+			},
+			want: []string{"reload"},
+		},
+		{
+			name: "stylesheets",
+			sends: func(h *ReloadHub) {
+				h.ReloadCSS()
+				h.ReportError(errors.New("broken"))
+				h.ReloadCSS()
+			},
+			want: []string{"css", "build-error"},
+		},
+		{
+			name: "stylesheets before reload",
+			sends: func(h *ReloadHub) {
+				h.ReloadCSS()
+				h.ReloadAll()
+			},
+			want: []string{"reload"},
+		},
+	}
+
+	for _, tt := range tests {
+		h := NewReloadHub()
+		c := &reloadClient{page: "/a", wake: make(chan struct{}, 1)}
+		h.clients[c] = struct{}{}
+
+		tt.sends(h)
+
+		var got []string
+		for _, event := range c.take() {
+			got = append(got, event.name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: events = %v, want %v", tt.name, got, tt.want)
+		}
+		if len(c.wake) != min(len(tt.want), 1) {
+			t.Errorf("%s: client woken %d times", tt.name, len(c.wake))
+		}
+	}
+}
@@ -2,40 +2,42 @@ package server
 
 import (
 	"fmt"
+	"geode/internal/output"
+	"io/fs"
 	"log"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 )
 
-func ServePublic(port int) {
-	fs := http.FileServer(http.Dir("public"))
-
+func ServePublic(out output.Output, hub *ReloadHub, port int) {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Resolved on every request, so a full rebuild that publishes a new
+		// output is picked up immediately.
+		fsys := out.FS()
+
 		path := filepath.Clean(r.URL.Path)
 
 		if path == "/" {
-			http.ServeFile(w, r, "public/index.html")
+			http.ServeFileFS(w, r, fsys, "index.html")
 			return
 		}
 
-		rel := strings.TrimPrefix(path, "/")
-		htmlPath := filepath.Join("public", rel) + ".html"
+		rel := strings.TrimPrefix(filepath.ToSlash(path), "/")
+		htmlPath := rel + ".html"
 
-		if fi, err := os.Stat(htmlPath); err == nil && !fi.IsDir() {
-			http.ServeFile(w, r, htmlPath)
+		if fi, err := fs.Stat(fsys, htmlPath); err == nil && !fi.IsDir() {
+			http.ServeFileFS(w, r, fsys, htmlPath)
 			return
 		}
 
-		fullPath := filepath.Join("public", rel)
-		if _, err := os.Stat(fullPath); err == nil {
-			fs.ServeHTTP(w, r)
+		if _, err := fs.Stat(fsys, rel); err == nil {
+			http.FileServerFS(fsys).ServeHTTP(w, r)
 			return
 		}
 
-		if content, err := os.ReadFile("public/404.html"); err == nil {
+		if content, err := fs.ReadFile(fsys, "404.html"); err == nil {
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.WriteHeader(http.StatusNotFound)
 			w.Write(content)
@@ -45,45 +47,7 @@ func ServePublic(port int) {
 		}
 	})
 
-	http.HandleFunc("/_reload", sseHandler)
+	http.Handle("/_reload", hub)
 
 	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(port), nil))
 }
-
-var sseClients = make(map[chan string]bool)
-
-func sseHandler(w http.ResponseWriter, r *http.Request) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
-	ch := make(chan string, 1)
-	sseClients[ch] = true
-
-	defer func() {
-		delete(sseClients, ch)
-		close(ch)
-	}()
-
-	for {
-		select {
-		case msg := <-ch:
-			fmt.Fprintf(w, "data: %s\n\n", msg)
-			flusher.Flush()
-		case <-r.Context().Done():
-			return
-		}
-	}
-}
-
-func BroadcastReload() {
-	for ch := range sseClients {
-		ch <- "reload"
-	}
-}
@@ -0,0 +1,528 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"geode/internal/build"
+	"geode/internal/cache"
+	"geode/internal/config"
+	"geode/internal/content"
+	"geode/internal/output"
+	"geode/internal/pagefind"
+	"geode/internal/render"
+	"geode/internal/render/media"
+	"geode/internal/types"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Site is the in-memory model of a built site. It remembers the rendered
+// pages and their embed and link dependencies, so that a change to a single
+// note only re-renders the pages that depend on it.
+type Site struct {
+	mu sync.Mutex
+
+	dir  string
+	cfg  *config.Config
+	live bool
+	out  output.Output
+
+	model
+}
+
+// model is the state produced by a full build.
+type model struct {
+	entries  []content.FileEntry
+	pipeline *render.Pipeline
+	writer   *build.HTMLWriter
+	fileTree *types.FileTree
+	pages    []types.MetaMarkdown
+}
+
+// NewSite prepares a site built from dir into out. Full builds are staged and
+// only published into out once they succeed.
+func NewSite(dir string, cfg *config.Config, out output.Output, live bool) *Site {
+	return &Site{
+		dir:  dir,
+		cfg:  cfg,
+		live: live,
+		out:  out,
+	}
+}
+
+// Build runs the full pipeline and replaces the in-memory model.
+func (s *Site) Build() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.build()
+}
+
+// Config returns the config the site is currently built with.
+func (s *Site) Config() *config.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cfg
+}
+
+// Reconfigure switches the site to cfg and rebuilds it. A config naming a
+// theme that does not exist, or one the site fails to build with, is
+// rejected and the current config is kept.
+func (s *Site) Reconfigure(cfg *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join("themes", cfg.Theme)); err != nil {
+		return fmt.Errorf("theme %s: %w", cfg.Theme, err)
+	}
+
+	if cfg.Build.Output != s.cfg.Build.Output {
+		log.Printf("build.output changed to %s, restart the server to use it", cfg.Build.Output)
+	}
+
+	old := s.cfg
+	s.cfg = cfg
+	if err := s.build(); err != nil {
+		// Keep serving the last site that built, with its config.
+		s.cfg = old
+		return err
+	}
+
+	return nil
+}
+
+func (s *Site) build() error {
+	out, err := s.out.Stage()
+	if err != nil {
+		return fmt.Errorf("stage output dir: %w", err)
+	}
+
+	m, err := s.buildInto(out)
+	if err != nil {
+		_ = out.Discard()
+		return err
+	}
+
+	if err := out.Commit(); err != nil {
+		_ = out.Discard()
+		return err
+	}
+	s.out = out
+	s.model = m
+
+	if s.live {
+		fmt.Println("Site rebuilt.")
+	} else {
+		fmt.Println("Build completed.")
+	}
+
+	return nil
+}
+
+// buildInto runs the full pipeline into out.
+func (s *Site) buildInto(out output.Output) (model, error) {
+	cfg, live := s.cfg, s.live
+
+	entries, err := content.GetAllMarkdownAndAssets(s.dir, cfg)
+	if err != nil {
+		return model{}, err
+	}
+
+	// A build skips notes with broken frontmatter. While serving, fail it
+	// instead so the error is shown rather than the note silently vanishing.
+	if live {
+		if err := checkFrontmatter(entries); err != nil {
+			return model{}, err
+		}
+	}
+
+	filtered := content.FilterEntries(entries, cfg)
+
+	// A site served from memory leaves nothing on disk, not even a cache.
+	var buildCache *cache.Cache
+	if _, inMemory := out.(*output.Memory); !inMemory {
+		if buildCache, err = cache.Open(cfg); err != nil {
+			return model{}, fmt.Errorf("open build cache: %w", err)
+		}
+	}
+
+	providers, err := media.NewProviders(cfg.Embeds.Providers, cfg.Embeds.ClickToLoad)
+	if err != nil {
+		return model{}, err
+	}
+
+	markPrivate := cfg.Build.UnpublishedLinks == config.UnpublishedPrivate
+	pipeline := render.NewPipeline(filtered, content.Unpublished(entries, filtered), markPrivate, providers, cfg.Build.Jobs, buildCache)
+	pages := pipeline.Render(filtered)
+	render.MergeBacklinks(pages)
+
+	if err := buildCache.Prune(); err != nil {
+		return model{}, fmt.Errorf("prune build cache: %w", err)
+	}
+
+	fileTree := render.BuildFileTree(pages)
+
+	writer, err := build.NewHTMLWriter(cfg, out)
+	if err != nil {
+		return model{}, fmt.Errorf("init html writer: %w", err)
+	}
+
+	if err := WriteSite(cfg, out, writer, filtered, pages, live, fileTree); err != nil {
+		return model{}, err
+	}
+
+	// Build pagefind index
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if err := runPagefind(ctx, out); err != nil {
+		return model{}, fmt.Errorf("build pagefind index: %w", err)
+	}
+
+	// Build sitemap
+	fmt.Println(cfg.Site.BaseURL)
+	if err := build.BuildSitemap(cfg, out, pages); err != nil {
+		return model{}, fmt.Errorf("build sitemap: %w", err)
+	}
+
+	if err := build.ReportLinks(cfg, pages); err != nil {
+		return model{}, err
+	}
+
+	return model{
+		entries:  filtered,
+		pipeline: pipeline,
+		writer:   writer,
+		fileTree: fileTree,
+		pages:    pages,
+	}, nil
+}
+
+// WriteSite writes pages with writer and everything derived from them into
+// out, the output of writer: tag pages, the 404 page, redirect stubs, theme
+// assets and the content files of entries.
+func WriteSite(cfg *config.Config, out output.Output, writer *build.HTMLWriter, entries []content.FileEntry, pages []types.MetaMarkdown, live bool, fileTree *types.FileTree) error {
+	if err := writer.WriteAll(pages, live, fileTree, cfg.Build.Jobs); err != nil {
+		return err
+	}
+
+	if err := build.BuildTagsIndex(cfg, out, pages, live, fileTree); err != nil {
+		return fmt.Errorf("build tags index: %w", err)
+	}
+	if err := build.BuildTagPages(cfg, out, pages, live, fileTree); err != nil {
+		return fmt.Errorf("build tag pages: %w", err)
+	}
+
+	// TODO: Build default directory pages
+	if err := build.Build404(cfg, out, live, fileTree); err != nil {
+		return fmt.Errorf("build 404 page: %w", err)
+	}
+
+	if err := build.BuildRedirects(cfg, out, pages); err != nil {
+		return fmt.Errorf("build redirects: %w", err)
+	}
+
+	if err := CopyThemeAssets(cfg, out); err != nil {
+		return err
+	}
+
+	if err := CopyContentAssets(entries, pages, cfg, out); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func checkFrontmatter(entries []content.FileEntry) error {
+	for _, entry := range entries {
+		if !entry.IsMarkdown {
+			continue
+		}
+		if _, err := content.ReadFrontmatter(entry.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPagefind indexes the site in out. Pagefind only works on directories, so
+// the pages of outputs that do not live on disk are exported to a temporary
+// directory and the generated index is copied back. Pagefind reads nothing
+// but the HTML files.
+func runPagefind(ctx context.Context, out output.Output) error {
+	if disk, ok := out.(*output.Disk); ok {
+		return pagefind.Run(ctx, disk.Dir())
+	}
+
+	tmp, err := os.MkdirTemp("", "geode-pagefind-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := exportPages(out.FS(), tmp); err != nil {
+		return err
+	}
+
+	if err := pagefind.Run(ctx, tmp); err != nil {
+		return err
+	}
+
+	return out.CopyDir(filepath.Join(tmp, "pagefind"), "pagefind")
+}
+
+func exportPages(fsys fs.FS, dir string) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".html" {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		dest := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(dest, data, 0o644)
+	})
+}
+
+// Changes describes what an update touched, so that live reload can target
+// the affected pages.
+type Changes struct {
+	Full  bool     // any page may have changed
+	Pages []string // URLs of the rewritten pages
+}
+
+// Update applies in-place edits of existing content files. It falls back to a
+// full build whenever an edit changes something every page depends on: the
+// set of published notes, a note's title, permalink or aliases, or the set of
+// tags.
+// The search index is not refreshed by incremental updates.
+func (s *Site) Update(paths []string) (Changes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pipeline == nil {
+		return s.fullBuild()
+	}
+
+	var notes, assets []content.FileEntry
+	for _, path := range paths {
+		entry, ok := s.entry(path)
+		if !ok {
+			return s.fullBuild()
+		}
+
+		if !entry.IsMarkdown {
+			assets = append(assets, entry)
+			continue
+		}
+
+		if len(content.FilterEntries([]content.FileEntry{entry}, s.cfg)) == 0 {
+			return s.fullBuild()
+		}
+		notes = append(notes, entry)
+	}
+
+	if err := CopyContentAssets(assets, s.pages, s.cfg, s.out); err != nil {
+		return Changes{}, err
+	}
+
+	// Any page may show an asset, there is no need to track which, except
+	// for the pages rendering one from its contents.
+	dirty := s.embedders(assets)
+	if len(notes) == 0 && len(dirty) == 0 {
+		return Changes{Full: true}, nil
+	}
+
+	s.pipeline.Refresh(notes)
+	maps.Copy(dirty, s.dependents(notes))
+
+	dirtyEntries := make([]content.FileEntry, 0, len(dirty))
+	for _, entry := range s.entries {
+		if _, ok := dirty[entry.Path]; ok {
+			dirtyEntries = append(dirtyEntries, entry)
+		}
+	}
+
+	rendered := s.pipeline.Render(dirtyEntries)
+	if len(rendered) != len(dirtyEntries) {
+		return s.fullBuild()
+	}
+
+	pages := slices.Clone(s.pages)
+	for _, page := range rendered {
+		i := s.pageIndex(page.Path)
+		if i < 0 {
+			return s.fullBuild()
+		}
+		old := pages[i]
+		if old.Link != page.Link || old.Title != page.Title || !slices.Equal(old.Tags, page.Tags) ||
+			!slices.Equal(old.Aliases, page.Aliases) || !slices.Equal(old.RedirectFrom, page.RedirectFrom) {
+			return s.fullBuild()
+		}
+		pages[i] = page
+	}
+
+	// The edited notes may have started embedding text files, which are only
+	// published once embedded.
+	var texts []content.FileEntry
+	for _, entry := range s.entries {
+		if entry.IsText {
+			texts = append(texts, entry)
+		}
+	}
+	if err := CopyContentAssets(texts, rendered, s.cfg, s.out); err != nil {
+		return Changes{}, err
+	}
+
+	render.MergeBacklinks(pages)
+
+	if err := build.ReportLinks(s.cfg, pages); err != nil {
+		return Changes{}, err
+	}
+
+	changed := make([]types.MetaMarkdown, 0, len(dirty))
+	for i, page := range pages {
+		_, isDirty := dirty[page.Path]
+		if isDirty || !slices.Equal(page.Backlinks, s.pages[i].Backlinks) {
+			changed = append(changed, page)
+		}
+	}
+
+	if err := s.writer.WriteAll(changed, s.live, s.fileTree, s.cfg.Build.Jobs); err != nil {
+		return Changes{}, err
+	}
+
+	if err := build.BuildSitemap(s.cfg, s.out, pages); err != nil {
+		return Changes{}, fmt.Errorf("build sitemap: %w", err)
+	}
+
+	s.pages = pages
+
+	fmt.Printf("Site updated (%d pages).\n", len(changed))
+
+	urls := make([]string, 0, len(changed))
+	for _, page := range changed {
+		urls = append(urls, page.Link)
+	}
+
+	// Assets copied alongside notes may be shown on any page.
+	return Changes{Full: len(assets) > 0, Pages: urls}, nil
+}
+
+// UpdateThemeAssets copies changed files of the theme's assets directory
+// into the output without rebuilding any page.
+func (s *Site) UpdateThemeAssets(paths []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assetsDir := filepath.Join("themes", s.cfg.Theme, "assets")
+	for _, path := range paths {
+		rel, err := filepath.Rel(assetsDir, path)
+		if err != nil {
+			return err
+		}
+
+		if err := s.out.CopyFile(path, filepath.ToSlash(rel)); err != nil {
+			return fmt.Errorf("copy theme asset %s: %w", rel, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Site) fullBuild() (Changes, error) {
+	return Changes{Full: true}, s.build()
+}
+
+// dependents returns the source paths of the changed notes together with
+// every page that embeds them, links to them or is listed in their backlinks.
+func (s *Site) dependents(notes []content.FileEntry) map[string]struct{} {
+	dirty := make(map[string]struct{})
+
+	for _, note := range notes {
+		dirty[note.Path] = struct{}{}
+
+		i := s.pageIndex(note.Path)
+		if i < 0 {
+			continue
+		}
+		target := s.pages[i]
+
+		for _, back := range target.Backlinks {
+			for _, page := range s.pages {
+				if page.Link == back.URL {
+					dirty[page.Path] = struct{}{}
+				}
+			}
+		}
+
+		for _, page := range s.pages {
+			if slices.Contains(page.Embeds, note.Path) {
+				dirty[page.Path] = struct{}{}
+				continue
+			}
+
+			for _, link := range page.OutgoingLinks {
+				if linkTarget(link.URL) == target.Link {
+					dirty[page.Path] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+
+	return dirty
+}
+
+// embedders returns the source paths of the pages that embed any of assets.
+func (s *Site) embedders(assets []content.FileEntry) map[string]struct{} {
+	dirty := make(map[string]struct{})
+	for _, asset := range assets {
+		for _, page := range s.pages {
+			if slices.Contains(page.Embeds, asset.Path) {
+				dirty[page.Path] = struct{}{}
+			}
+		}
+	}
+	return dirty
+}
+
+func (s *Site) entry(path string) (content.FileEntry, bool) {
+	path = filepath.Clean(path)
+	for _, entry := range s.entries {
+		if filepath.Clean(entry.Path) == path {
+			return entry, true
+		}
+	}
+	return content.FileEntry{}, false
+}
+
+func (s *Site) pageIndex(path string) int {
+	for i, page := range s.pages {
+		if page.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+func linkTarget(url string) string {
+	if i := strings.IndexByte(url, '#'); i >= 0 {
+		return url[:i]
+	}
+	return url
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"geode/internal/cache"
+	"geode/internal/config"
+	"geode/internal/output"
+)
+
+// newTestSite builds a site from files, keyed by their path in the vault,
+// into memory. It runs in a directory of its own with a minimal theme and a
+// stand-in for pagefind.
+func newTestSite(t *testing.T, files map[string]string) (*Site, *output.Memory, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	site := map[string]string{
+		config.ConfigFile:                 "site:\n  name: Test\n  base_url: https://example.com\nbuild:\n  output: public\n  mode: draft\ntheme: test\n",
+		"themes/test/templates/base.html": "{{.Title}}\n{{.Content}}\n",
+		"themes/test/templates/tag.html":  "{{.Tag}}\n",
+		"themes/test/templates/tags.html": "tags\n",
+		"themes/test/assets/style.css":    "body {}\n",
+		"bin/pagefind":                    "#!/bin/sh\nmkdir -p \"$2/pagefind\"\n",
+	}
+	for rel, data := range files {
+		site["vault/"+rel] = data
+	}
+	for rel, data := range site {
+		writeFile(t, rel, data)
+	}
+	if err := os.Chmod(filepath.Join("bin", "pagefind"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := output.NewMemory()
+	s := NewSite("vault", cfg, out, false)
+	if err := s.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return s, out, "vault"
+}
+
+func writeFile(t *testing.T, rel, data string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(rel), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(rel, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var testVault = map[string]string{
+	"index.md":   "# Home\n\nSee [[a]].\n",
+	"a.md":       "Embeds:\n\n![[b]]\n",
+	"b.md":       "B body.\n",
+	"c.md":       "C alone.\n",
+	"code.md":    "![[snippet.go]]\n",
+	"snippet.go": "package snippet\n",
+}
+
+func TestUpdateRewritesDependents(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		data      string
+		wantPages []string
+		wantFull  bool
+		wantHTML  map[string]string // page file -> part of its new HTML
+	}{
+		{
+			name:      "note on its own",
+			file:      "c.md",
+			data:      "C edited.\n",
+			wantPages: []string{"/c"},
+			wantHTML:  map[string]string{"c.html": "C edited."},
+		},
+		{
+			name:      "embedded note",
+			file:      "b.md",
+			data:      "B edited.\n",
+			wantPages: []string{"/a", "/b"},
+			wantHTML:  map[string]string{"a.html": "B edited.", "b.html": "B edited."},
+		},
+		{
+			name:      "embedded text file",
+			file:      "snippet.go",
+			data:      "package edited\n",
+			wantPages: []string{"/code"},
+			wantFull:  true,
+			wantHTML:  map[string]string{"code.html": "edited", "snippet.go": "package edited"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, out, vault := newTestSite(t, testVault)
+
+			path := filepath.Join(vault, tt.file)
+			writeFile(t, path, tt.data)
+
+			changes, err := s.Update([]string{path})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			slices.Sort(changes.Pages)
+			if changes.Full != tt.wantFull || !slices.Equal(changes.Pages, tt.wantPages) {
+				t.Errorf("changes = %+v, want pages %v, full %v", changes, tt.wantPages, tt.wantFull)
+			}
+			for file, want := range tt.wantHTML {
+				if got := readOutput(t, out, file); !strings.Contains(got, want) {
+					t.Errorf("%s lacks %q:\n%s", file, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateFallsBackToFullBuild(t *testing.T) {
+	tests := []struct {
+		name, data string
+	}{
+		{"title", "---\ntitle: Renamed\n---\nC alone.\n"},
+		{"permalink", "---\npermalink: /elsewhere\n---\nC alone.\n"},
+		{"tags", "---\ntags: [new]\n---\nC alone.\n"},
+		{"aliases", "---\naliases: [See]\n---\nC alone.\n"},
+		{"redirect_from", "---\nredirect_from: [/old-c]\n---\nC alone.\n"},
+		{"unpublished", "---\ndraft: true\n---\nC alone.\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, out, vault := newTestSite(t, testVault)
+
+			path := filepath.Join(vault, "c.md")
+			writeFile(t, path, tt.data)
+
+			changes, err := s.Update([]string{path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !changes.Full || changes.Pages != nil {
+				t.Errorf("changes = %+v, want a full build", changes)
+			}
+
+			if tt.name == "permalink" {
+				readOutput(t, out, "elsewhere.html")
+				if _, err := fs.Stat(out.FS(), "c.html"); err == nil {
+					t.Error("c.html is still published")
+				}
+			}
+		})
+	}
+
+	t.Run("new note", func(t *testing.T) {
+		s, out, vault := newTestSite(t, testVault)
+
+		path := filepath.Join(vault, "d.md")
+		writeFile(t, path, "D.\n")
+
+		if changes, err := s.Update([]string{path}); err != nil || !changes.Full {
+			t.Fatalf("Update() = %+v, %v, want a full build", changes, err)
+		}
+		readOutput(t, out, "d.html")
+	})
+}
+
+func TestUpdateRewritesSitemap(t *testing.T) {
+	s, out, vault := newTestSite(t, testVault)
+
+	path := filepath.Join(vault, "c.md")
+	writeFile(t, path, "---\nmodified: 2024-01-02\n---\nC alone.\n")
+
+	changes, err := s.Update([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Full {
+		t.Errorf("changes = %+v, want an incremental update", changes)
+	}
+
+	sitemap := readOutput(t, out, "sitemap.xml")
+	if want := "<loc>https://example.com/c</loc>\n    <lastmod>2024-01-02</lastmod>"; !strings.Contains(sitemap, want) {
+		t.Errorf("sitemap lacks %s:\n%s", want, sitemap)
+	}
+}
+
+func TestMemoryBuildLeavesNoCache(t *testing.T) {
+	s, _, vault := newTestSite(t, testVault)
+
+	path := filepath.Join(vault, "c.md")
+	writeFile(t, path, "C edited.\n")
+	if _, err := s.Update([]string{path}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(cache.DefaultDir); !os.IsNotExist(err) {
+		t.Errorf("stat %s = %v, want no cache on disk", cache.DefaultDir, err)
+	}
+}
+
+func readOutput(t *testing.T, out *output.Memory, rel string) string {
+	t.Helper()
+
+	data, err := fs.ReadFile(out.FS(), rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
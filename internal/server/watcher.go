@@ -1,18 +1,16 @@
 package server
 
 import (
-	"context"
 	"fmt"
-	"geode/internal/build"
 	"geode/internal/config"
 	"geode/internal/content"
-	"geode/internal/pagefind"
-	"geode/internal/render"
+	"geode/internal/output"
+	"geode/internal/types"
 	"geode/internal/utils"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -20,27 +18,33 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
-func WatchAndRebuild(contentDir string, cfg *config.Config) {
+func WatchAndRebuild(site *Site, hub *ReloadHub) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer watcher.Close()
 
-	themesPath := filepath.Join("themes", cfg.Theme)
+	themesPath := filepath.Join("themes", site.Config().Theme)
 
-	if err := watchRecursive(watcher, contentDir); err != nil {
+	if err := watchRecursive(watcher, site.dir); err != nil {
 		log.Fatal(err)
 	}
 	if err := watchRecursive(watcher, themesPath); err != nil {
 		log.Fatal(err)
 	}
+	// Watch the directory rather than the file itself, editors often save by
+	// replacing the file, which drops a watch on it.
+	if err := watcher.Add(filepath.Dir(config.ConfigFile)); err != nil {
+		log.Fatal(err)
+	}
 
 	fmt.Println("Watching for changes...")
 
 	var (
 		debounce *time.Timer
 		mu       sync.Mutex
+		pending  = make(map[string]fsnotify.Op)
 	)
 
 	for {
@@ -60,28 +64,57 @@ func WatchAndRebuild(contentDir string, cfg *config.Config) {
 				continue
 			}
 
+			path := filepath.Clean(e.Name)
+
+			mu.Lock()
+			relevant := path == config.ConfigFile || isWithin(site.dir, path) || isWithin(themesPath, path)
+			mu.Unlock()
+
+			// The config directory also holds the output and other files
+			// that must not trigger a rebuild.
+			if !relevant {
+				continue
+			}
+
 			if e.Op&fsnotify.Create != 0 {
 				if info, err := os.Stat(e.Name); err == nil && info.IsDir() {
 					_ = watchRecursive(watcher, e.Name)
 				}
 			}
 
-			event := e
-
 			mu.Lock()
+			pending[path] |= e.Op
+
 			if debounce != nil {
 				debounce.Stop()
 			}
 
 			debounce = time.AfterFunc(200*time.Millisecond, func() {
-				fmt.Println("Changed:", event.Name)
+				mu.Lock()
+				changes := pending
+				pending = make(map[string]fsnotify.Op)
+				themes := themesPath
+				mu.Unlock()
 
-				if err := Rebuild(contentDir, cfg, true); err != nil {
-					log.Println("Rebuild error:", err)
+				if len(changes) == 0 {
 					return
 				}
 
-				BroadcastReload()
+				if err := applyChanges(site, hub, themes, changes); err != nil {
+					log.Println("Rebuild error:", err)
+					hub.ReportError(err)
+				}
+
+				// Follow a theme switch in the config.
+				if current := filepath.Join("themes", site.Config().Theme); current != themes {
+					if err := rewatch(watcher, themes, current); err != nil {
+						log.Println("Watcher error:", err)
+					}
+
+					mu.Lock()
+					themesPath = current
+					mu.Unlock()
+				}
 			})
 			mu.Unlock()
 
@@ -91,103 +124,141 @@ func WatchAndRebuild(contentDir string, cfg *config.Config) {
 	}
 }
 
-func watchRecursive(w *fsnotify.Watcher, root string) error {
-	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if d.IsDir() {
-			if err := w.Add(path); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-}
-
-func Rebuild(dir string, cfg *config.Config, live bool) error {
-	if err := CleanPublicDir(); err != nil {
-		return fmt.Errorf("clean public dir: %w", err)
+// applyChanges updates the site incrementally when only existing content
+// files were written to, and rebuilds it completely otherwise. Connected
+// browsers are reloaded only when the page they show changed, and stylesheet
+// edits are swapped in without a reload.
+func applyChanges(site *Site, hub *ReloadHub, themesPath string, changes map[string]fsnotify.Op) error {
+	if _, ok := changes[config.ConfigFile]; ok {
+		fmt.Println("Changed:", config.ConfigFile)
+		return reloadConfig(site, hub)
 	}
 
-	entries, err := content.GetAllMarkdownAndAssets(dir, cfg)
-	if err != nil {
-		return err
-	}
+	paths := make([]string, 0, len(changes))
+	full := false
+	cssOnly := true
 
-	filtered := content.FilterEntries(entries, cfg)
+	for name, op := range changes {
+		fmt.Println("Changed:", name)
 
-	pages := render.ParsingMarkdown(filtered)
+		if op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 || isWithin(themesPath, name) {
+			full = true
+		}
+		if op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 ||
+			!isWithin(filepath.Join(themesPath, "assets"), name) ||
+			filepath.Ext(name) != ".css" {
+			cssOnly = false
+		}
+		paths = append(paths, name)
+	}
 
-	fileTree := render.BuildFileTree(pages)
+	sort.Strings(paths)
 
-	writer, err := build.NewHTMLWriter(cfg)
-	if err != nil {
-		return fmt.Errorf("init html writer: %w", err)
+	if cssOnly {
+		if err := site.UpdateThemeAssets(paths); err != nil {
+			return err
+		}
+		hub.ReloadCSS()
+		return nil
 	}
 
-	for _, page := range pages {
-		if err := writer.Write(page, live, fileTree); err != nil {
-			return fmt.Errorf("write html %s: %w", page.RelativePath, err)
+	if full {
+		if err := site.Build(); err != nil {
+			return err
 		}
+		hub.ClearError()
+		hub.ReloadAll()
+		return nil
 	}
 
-	if err := build.BuildTagsIndex(cfg, pages, live, fileTree); err != nil {
-		return fmt.Errorf("build tags index: %w", err)
-	}
-	if err := build.BuildTagPages(cfg, pages, live, fileTree); err != nil {
-		return fmt.Errorf("build tag pages: %w", err)
+	changed, err := site.Update(paths)
+	if err != nil {
+		return err
 	}
+	hub.ClearError()
 
-	// TODO: Build default directory pages
-	if err := build.Build404(cfg, live, fileTree); err != nil {
-		return fmt.Errorf("build 404 page: %w", err)
+	if changed.Full {
+		hub.ReloadAll()
+	} else {
+		hub.ReloadPages(changed.Pages)
 	}
+	return nil
+}
 
-	if err := CopyThemeAssets(cfg); err != nil {
-		return err
+// reloadConfig re-reads the config file and rebuilds the site with it. An
+// invalid config is reported and the site keeps running on the previous one.
+func reloadConfig(site *Site, hub *ReloadHub) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("%s: %w", config.ConfigFile, err)
 	}
 
-	if err := CopyContentAssets(filtered, cfg); err != nil {
+	if err := site.Reconfigure(cfg); err != nil {
 		return err
 	}
 
-	// Build pagefind index
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-	defer cancel()
+	hub.ClearError()
+	hub.ReloadAll()
+	return nil
+}
 
-	if err := pagefind.Run(ctx, cfg.Build.Output); err != nil {
-		return fmt.Errorf("build pagefind index: %w", err)
+// rewatch moves the recursive watch on oldRoot over to newRoot.
+func rewatch(w *fsnotify.Watcher, oldRoot, newRoot string) error {
+	for _, path := range w.WatchList() {
+		if isWithin(oldRoot, path) {
+			_ = w.Remove(path)
+		}
 	}
+	return watchRecursive(w, newRoot)
+}
 
-	// Build sitemap
-	fmt.Println(cfg.Site.BaseURL)
-	if err := build.BuildSitemap(cfg, pages); err != nil {
-		return fmt.Errorf("build sitemap: %w", err)
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
 	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
 
-	if live {
-		fmt.Println("Site rebuilt.")
-	} else {
-		fmt.Println("Build completed.")
-	}
+func watchRecursive(w *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 
-	return nil
+		if d.IsDir() {
+			if err := w.Add(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func CleanPublicDir() error {
-	err := os.RemoveAll("public")
-	if err != nil {
-		return err
+func Rebuild(dir string, cfg *config.Config, live bool) error {
+	return NewSite(dir, cfg, output.New(cfg), live).Build()
+}
+
+// CopyContentAssets copies the assets of entries to the site. Code and text
+// files are only copied when one of pages embeds them, so that scripts and
+// config files living next to notes stay private.
+func CopyContentAssets(entries []content.FileEntry, pages []types.MetaMarkdown, cfg *config.Config, out output.Output) error {
+	type asset struct {
+		rel  string
+		src  string
+		dest string
 	}
 
-	return os.MkdirAll("public", 0o755)
-}
+	embedded := make(map[string]struct{})
+	for _, page := range pages {
+		for _, path := range page.Embeds {
+			embedded[path] = struct{}{}
+		}
+	}
 
-func CopyContentAssets(entries []content.FileEntry, cfg *config.Config) error {
+	assets := make([]asset, 0, len(entries))
 	for _, entry := range entries {
-		if !entry.IsAsset {
+		if _, ok := embedded[entry.Path]; !entry.IsAsset && !(entry.IsText && ok) {
 			continue
 		}
 
@@ -195,24 +266,31 @@ func CopyContentAssets(entries []content.FileEntry, cfg *config.Config) error {
 			continue
 		}
 
-		ext := filepath.Ext(entry.RelativePath)
-		relPathNoExt := strings.TrimSuffix(entry.RelativePath, ext)
-		relPathNoExt = strings.ReplaceAll(relPathNoExt, "\\", "/")
+		normalizedPath := utils.AssetPath(entry.RelativePath)
+		if err := out.Claim(normalizedPath, entry.RelativePath); err != nil {
+			return err
+		}
+
+		assets = append(assets, asset{rel: entry.RelativePath, src: entry.Path, dest: normalizedPath})
+	}
 
-		normalizedPath := utils.PathToSlug(relPathNoExt) + ext
-		destPath := filepath.Join("public", normalizedPath)
+	errs := make([]error, len(assets))
+	utils.Parallel(len(assets), cfg.Build.Jobs, func(_, i int) {
+		errs[i] = out.CopyFile(assets[i].src, assets[i].dest)
+	})
 
-		if err := copyFile(entry.Path, destPath); err != nil {
-			return fmt.Errorf("copy asset %s: %w", entry.RelativePath, err)
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("copy asset %s: %w", assets[i].rel, err)
 		}
 	}
 
 	return nil
 }
 
-func CopyThemeAssets(cfg *config.Config) error {
+func CopyThemeAssets(cfg *config.Config, out output.Output) error {
 	srcDir := filepath.Join("themes", cfg.Theme, "assets")
-	return copyDirRecursive(srcDir, "public")
+	return out.CopyDir(srcDir, "")
 }
 
 func shouldIgnoreAsset(path string, patterns []string) bool {
@@ -238,50 +316,3 @@ func shouldIgnoreAsset(path string, patterns []string) bool {
 	}
 	return false
 }
-
-func copyDirRecursive(src, dest string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		rel, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
-		}
-
-		targetPath := filepath.Join(dest, rel)
-
-		if info.IsDir() {
-			return os.MkdirAll(targetPath, 0o755)
-		}
-
-		return copyFile(path, targetPath)
-	})
-}
-
-func copyFile(srcFile, destFile string) error {
-	src, err := os.Open(srcFile)
-	if err != nil {
-		return err
-	}
-	defer src.Close()
-
-	err = os.MkdirAll(filepath.Dir(destFile), 0o755)
-	if err != nil {
-		return err
-	}
-
-	dest, err := os.Create(destFile)
-	if err != nil {
-		return err
-	}
-	defer dest.Close()
-
-	_, err = io.Copy(dest, src)
-	if err != nil {
-		return err
-	}
-
-	return dest.Sync()
-}
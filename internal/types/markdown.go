@@ -5,6 +5,13 @@ type Link struct {
 	URL   string
 }
 
+// UnresolvedLink is a wikilink pointing at no published note.
+type UnresolvedLink struct {
+	Target      string
+	Unpublished bool
+	Embedded    string // the embedded note the link is in, empty for the page itself
+}
+
 type TocItem struct {
 	Level int
 	Text  string
@@ -23,8 +30,13 @@ type MetaMarkdown struct {
 	HTML            string
 	OutgoingLinks   []Link
 	Backlinks       []Link
+	Embeds          []string
 	TableOfContents []TocItem
 	HasKatex        bool
 	HasMermaid      bool
 	Description     string
+	Aliases         []string
+	RedirectFrom    []string
+	Warnings        []string
+	UnresolvedLinks []UnresolvedLink
 }
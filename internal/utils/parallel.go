@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Jobs returns the number of workers to use for a requested job count,
+// falling back to the number of CPUs when none was requested.
+func Jobs(n int) int {
+	if n <= 0 {
+		return runtime.NumCPU()
+	}
+	return n
+}
+
+// Parallel calls fn for every index in [0, n) on up to jobs goroutines.
+// The worker argument identifies the goroutine, so callers can keep
+// per-worker state in a slice of length Jobs(jobs).
+func Parallel(n, jobs int, fn func(worker, i int)) {
+	jobs = Jobs(jobs)
+	if jobs > n {
+		jobs = n
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range next {
+				fn(worker, i)
+			}
+		}(w)
+	}
+
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+
+	wg.Wait()
+}
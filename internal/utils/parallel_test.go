@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	tests := []struct {
+		n, jobs     int
+		wantWorkers int
+	}{
+		{100, 4, 4},
+		{3, 8, 3},
+		{0, 4, 0},
+		{10, 1, 1},
+	}
+
+	for _, tt := range tests {
+		var mu sync.Mutex
+		calls := make([]int, tt.n)
+		workers := make(map[int]bool)
+
+		Parallel(tt.n, tt.jobs, func(worker, i int) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[i]++
+			workers[worker] = true
+		})
+
+		for i, c := range calls {
+			if c != 1 {
+				t.Errorf("n=%d jobs=%d: index %d called %d times", tt.n, tt.jobs, i, c)
+			}
+		}
+		for w := range workers {
+			if w < 0 || w >= tt.wantWorkers {
+				t.Errorf("n=%d jobs=%d: worker %d out of range [0, %d)", tt.n, tt.jobs, w, tt.wantWorkers)
+			}
+		}
+	}
+}
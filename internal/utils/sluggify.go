@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"path"
 	"strings"
 )
 
@@ -27,3 +28,11 @@ func PathToSlug(path string) string {
 
 	return out
 }
+
+// AssetPath maps the path of an asset, relative to the content root, to the
+// path it is copied to in the output.
+func AssetPath(rel string) string {
+	rel = strings.ReplaceAll(rel, "\\", "/")
+	ext := path.Ext(rel)
+	return PathToSlug(strings.TrimSuffix(rel, ext)) + ext
+}
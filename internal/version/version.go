@@ -0,0 +1,29 @@
+package version
+
+import "runtime/debug"
+
+// Version can be overridden at link time with
+// -ldflags "-X geode/internal/version.Version=v1.2.3".
+var Version = "dev"
+
+// String identifies the running binary as precisely as possible, including
+// the VCS revision it was built from when that is known.
+func String() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Version
+	}
+
+	v := Version
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v += "+" + s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				v += "-dirty"
+			}
+		}
+	}
+	return v
+}
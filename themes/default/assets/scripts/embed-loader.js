@@ -0,0 +1,48 @@
+(function () {
+  const TWITTER_WIDGETS = "https://platform.twitter.com/widgets.js";
+
+  function loadTwitter(el) {
+    if (window.twttr && window.twttr.widgets) {
+      window.twttr.widgets.load(el);
+      return;
+    }
+    if (document.querySelector('script[src="' + TWITTER_WIDGETS + '"]')) {
+      return;
+    }
+    const s = document.createElement("script");
+    s.async = true;
+    s.src = TWITTER_WIDGETS;
+    s.charset = "utf-8";
+    document.body.appendChild(s);
+  }
+
+  function load(placeholder) {
+    const wrapper = document.createElement("span");
+    wrapper.className = "embed-loaded";
+    wrapper.innerHTML = placeholder.dataset.embed;
+
+    // Scripts set through innerHTML do not run.
+    wrapper.querySelectorAll("script").forEach(function (old) {
+      const s = document.createElement("script");
+      for (const attr of old.attributes) {
+        s.setAttribute(attr.name, attr.value);
+      }
+      s.text = old.text;
+      old.replaceWith(s);
+    });
+
+    placeholder.replaceWith(wrapper);
+
+    if (wrapper.querySelector(".twitter-tweet")) {
+      loadTwitter(wrapper);
+    }
+  }
+
+  document.addEventListener("click", function (e) {
+    const btn = e.target.closest(".embed-load");
+    if (!btn) return;
+
+    const placeholder = btn.closest(".embed-placeholder");
+    if (placeholder) load(placeholder);
+  });
+})();
@@ -0,0 +1,75 @@
+(function () {
+  const page = decodeURIComponent(location.pathname);
+  const source = new EventSource("/_reload?page=" + encodeURIComponent(page));
+
+  source.addEventListener("reload", () => {
+    location.reload();
+  });
+
+  // Swap stylesheets without losing scroll position or state.
+  source.addEventListener("css", () => {
+    document.querySelectorAll('link[rel="stylesheet"]').forEach((link) => {
+      const url = new URL(link.href);
+      if (url.origin !== location.origin) return;
+
+      url.searchParams.set("reload", Date.now());
+
+      const next = link.cloneNode();
+      next.href = url.toString();
+      next.addEventListener("load", () => link.remove());
+      next.addEventListener("error", () => next.remove());
+      link.after(next);
+    });
+  });
+
+  // Build errors stay on screen until the next successful rebuild.
+  source.addEventListener("build-error", (e) => {
+    showError(JSON.parse(e.data));
+  });
+
+  source.addEventListener("clear", () => {
+    document.getElementById("geode-error-overlay")?.remove();
+  });
+
+  function showError(err) {
+    let overlay = document.getElementById("geode-error-overlay");
+    if (!overlay) {
+      overlay = document.createElement("div");
+      overlay.id = "geode-error-overlay";
+      overlay.style.cssText =
+        "position:fixed;inset:0;z-index:2147483647;overflow:auto;" +
+        "padding:2rem;background:rgba(20,20,20,0.92);color:#f5f5f5;" +
+        "font:14px/1.5 ui-monospace,SFMono-Regular,Menlo,monospace;";
+      document.body.appendChild(overlay);
+    }
+
+    overlay.replaceChildren();
+
+    const title = document.createElement("div");
+    title.style.cssText = "color:#ff6b6b;font-weight:bold;margin-bottom:1rem;";
+    title.textContent = "Build failed";
+    overlay.appendChild(title);
+
+    if (err.path) {
+      const location = document.createElement("div");
+      location.style.cssText = "color:#ffd166;margin-bottom:0.5rem;";
+      location.textContent = err.line ? err.path + ":" + err.line : err.path;
+      overlay.appendChild(location);
+    }
+
+    const message = document.createElement("pre");
+    message.style.cssText = "margin:0;white-space:pre-wrap;";
+    message.textContent = err.message;
+    overlay.appendChild(message);
+
+    const close = document.createElement("button");
+    close.textContent = "Dismiss";
+    close.style.cssText = "margin-top:1.5rem;";
+    close.addEventListener("click", () => overlay.remove());
+    overlay.appendChild(close);
+  }
+
+  window.addEventListener("beforeunload", () => {
+    source.close();
+  });
+})();